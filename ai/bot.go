@@ -0,0 +1,209 @@
+// Package ai is a computer player for tytris. A bot steers each piece to wherever its brain decides it should go, using
+// the same actions a human player's inputs turn into. The built-in brain looks at every spot the piece (or the held
+// piece) can reach and scores the board each one would leave behind, and external bots can be plugged in instead. Like
+// the engine, it knows nothing about the UI, so it can play headlessly.
+package ai
+
+import (
+	"github.com/bennicholls/tytris/engine"
+)
+
+// Brain decides where pieces go. The bot asks it about each new piece, and steers the piece to wherever it says.
+type Brain interface {
+	// Choose picks the spot for the current piece. hold is true if the spot is for the piece that holding swaps in,
+	// which is only allowed if can_hold is. ok is false if the brain hasn't decided yet, in which case it's asked
+	// again next tick. It's also asked again if the piece can't get to the spot any more.
+	Choose(game *engine.Game, can_hold bool) (target engine.Piece, hold bool, ok bool)
+
+	// Close lets go of anything the brain is holding on to, returning whatever went wrong with it along the way.
+	Close() error
+}
+
+// Difficulty holds the bot back, so people stand a chance against it.
+type Difficulty struct {
+	PPS      float64 // most pieces placed per second. 0 places them as fast as the bot can manage
+	Mistakes float64 // chance of putting a piece somewhere other than the best spot, from 0 to 1. built-in brain only
+}
+
+// Difficulties are the preset difficulties, by name.
+var Difficulties = map[string]Difficulty{
+	"easy":   {PPS: 0.75, Mistakes: 0.25},
+	"medium": {PPS: 1.5, Mistakes: 0.1},
+	"hard":   {PPS: 3, Mistakes: 0.02},
+	"max":    {},
+}
+
+// the game runs at 60 ticks per second
+const ticks_per_second float64 = 60
+
+// Bot plays a game. Call Actions once per tick and pass what it returns to the game along with everything else.
+type Bot struct {
+	brain Brain
+	pps   float64 // most pieces placed per second. 0 is as fast as possible
+
+	dropped int // pieces dropped when the target was picked, to tell when there's a new piece. -1 before the first
+	swaps   int // same, but for holds
+
+	deciding bool         // waiting for the brain to choose a target
+	target   engine.Piece // where the current piece is going
+	hold     bool         // the target is for the piece in hold, so swap it in first
+	held     bool         // the current piece has already been swapped, so it can't be swapped again
+	dropping bool         // holding soft drop until the piece reaches the floor
+
+	wait        int // ticks until the next input
+	input_delay int // ticks between inputs for the current piece, to keep to the speed limit
+}
+
+// NewBot makes a bot that places pieces wherever the brain chooses, no faster than pps pieces per second.
+func NewBot(brain Brain, pps float64) *Bot {
+	b := &Bot{brain: brain, pps: pps}
+	b.Reset()
+	return b
+}
+
+// Reset forgets about the piece the bot was working on, ready for a new game.
+func (b *Bot) Reset() {
+	b.dropped, b.swaps = -1, -1
+	b.held = false
+	b.dropping = false
+	b.wait = 0
+}
+
+// Close is called once the bot is done playing. Returns whatever went wrong with its brain, if anything did.
+func (b *Bot) Close() error {
+	return b.brain.Close()
+}
+
+// Thinking reports whether the bot is still waiting on its brain to decide where the current piece goes. External
+// bots think in the background, so headless games can wait for them instead of letting the piece fall.
+func (b *Bot) Thinking() bool {
+	return b.deciding
+}
+
+// Actions decides what to do on the next tick of the game.
+func (b *Bot) Actions(game *engine.Game) (actions []engine.Action) {
+	piece := game.CurrentPiece()
+	if game.IsOver() || piece.Type == engine.NO_PIECE {
+		return
+	}
+
+	info := game.Info()
+	if info.PiecesDropped != b.dropped {
+		b.dropped, b.swaps = info.PiecesDropped, info.Swaps
+		b.held = false
+		b.deciding = true
+	} else if info.Swaps != b.swaps {
+		b.swaps = info.Swaps
+		b.held = true
+		if b.target.Type != piece.Type { // the hold was a mistake, or the target was never for this piece
+			b.deciding = true
+		}
+	}
+
+	if b.deciding && !b.choose(game) {
+		return
+	}
+
+	resting := piece.Pos == game.Ghost().Pos
+	if b.dropping {
+		if !resting {
+			return
+		}
+		actions = append(actions, engine.ACTION_SOFT_DROP_OFF)
+		b.dropping = false
+	}
+
+	// the bot takes its time, unless the piece is about to lock
+	lock_ticks, lock_delay := game.LockTimer()
+	if b.wait > 0 && (!resting || lock_ticks < lock_delay/2) {
+		b.wait -= 1
+		return
+	}
+
+	if b.hold {
+		b.hold = false
+		return append(actions, engine.ACTION_HOLD)
+	}
+
+	g := newGrid(game.Board())
+	moves, ok := search(g, piece, game.Kicks).path(g, b.target)
+	if !ok { // the piece fell past where it was going, so find somewhere else
+		b.deciding = true
+		return
+	}
+
+	b.wait = b.input_delay
+	if len(moves) == 0 {
+		return append(actions, engine.ACTION_HARD_DROP)
+	}
+
+	switch moves[0] {
+	case MOVE_LEFT:
+		actions = append(actions, engine.ACTION_MOVE_LEFT, engine.ACTION_RELEASE_LEFT)
+	case MOVE_RIGHT:
+		actions = append(actions, engine.ACTION_MOVE_RIGHT, engine.ACTION_RELEASE_RIGHT)
+	case MOVE_CW:
+		actions = append(actions, engine.ACTION_ROTATE_CW)
+	case MOVE_CCW:
+		actions = append(actions, engine.ACTION_ROTATE_CCW)
+	case MOVE_180:
+		actions = append(actions, engine.ACTION_ROTATE_180)
+	case MOVE_DROP:
+		actions = append(actions, engine.ACTION_SOFT_DROP_ON)
+		b.dropping = true
+	}
+
+	return
+}
+
+// choose asks the brain where the current piece is going, and works out how fast to move it there. Returns false if
+// the brain hasn't decided yet.
+func (b *Bot) choose(game *engine.Game) bool {
+	target, hold, ok := b.brain.Choose(game, !b.held)
+	if !ok {
+		return false
+	}
+
+	b.deciding = false
+	b.target = target
+	b.hold = hold && !b.held
+
+	// count the inputs it takes to get there, including the hold and the hard drop
+	g := newGrid(game.Board())
+	start := game.CurrentPiece()
+	if b.hold {
+		start = swapPiece(game)
+	}
+	moves, _ := search(g, start, game.Kicks).path(g, target)
+	inputs := len(moves) + 1
+	if b.hold {
+		inputs += 1
+	}
+
+	// the bot waits before every input and acts on the tick after, so a piece takes about inputs*(input_delay+1) ticks
+	b.input_delay = 0
+	if b.pps > 0 {
+		b.input_delay = max(int(ticks_per_second/b.pps/float64(inputs))-1, 0)
+	}
+	b.wait = b.input_delay // time to think
+
+	return true
+}
+
+// swapPiece returns the piece that holding would swap in, where it would spawn. Its type is NO_PIECE if there isn't
+// one.
+func swapPiece(game *engine.Game) (swap engine.Piece) {
+	swap = game.HeldPiece()
+	if swap.Type == engine.NO_PIECE {
+		if upcoming := game.UpcomingPieces(1); len(upcoming) > 0 {
+			swap = upcoming[0]
+		}
+	}
+
+	if swap.Type != engine.NO_PIECE {
+		swap.Rotation = 0
+		swap.Pos = swap.StartLocation(game.Board().Size().W)
+	}
+
+	return
+}
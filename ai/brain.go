@@ -0,0 +1,83 @@
+package ai
+
+import (
+	"cmp"
+	"math/rand"
+	"slices"
+
+	"github.com/bennicholls/tytris/engine"
+)
+
+// when the heuristic makes a mistake, the piece goes in one of this many next-best spots instead of the best one
+const mistake_choices int = 4
+
+// Heuristic is the built-in brain. It tries the current piece (and the one holding would swap in) in every spot it
+// can reach, and picks the one that leaves the best board according to its weights.
+type Heuristic struct {
+	Weights  Weights
+	Mistakes float64 // chance of picking somewhere other than the best spot, from 0 to 1
+
+	rng *rand.Rand
+}
+
+// NewHeuristic makes a brain that plays with the default weights. The seed decides when it makes mistakes, so the same
+// seed plays the same game the same way.
+func NewHeuristic(mistakes float64, seed int64) *Heuristic {
+	return &Heuristic{Weights: DefaultWeights, Mistakes: mistakes, rng: rand.New(rand.NewSource(seed))}
+}
+
+// placement is a spot a piece can be locked in.
+type placement struct {
+	piece engine.Piece // the piece, where it would lock
+	hold  bool         // the piece has to come out of hold first
+	score float64
+}
+
+func (h *Heuristic) Choose(game *engine.Game, can_hold bool) (target engine.Piece, hold bool, ok bool) {
+	g := newGrid(game.Board())
+	current := game.CurrentPiece()
+	candidates := h.placements(g, game, current, false)
+
+	if swap := swapPiece(game); can_hold && swap.Type != engine.NO_PIECE && swap.Type != current.Type {
+		candidates = append(candidates, h.placements(g, game, swap, true)...)
+	}
+
+	if len(candidates) == 0 {
+		return current, false, true // nowhere to go, the game is about to end anyways
+	}
+
+	// best first. the held piece's spots come last, so it's only swapped in when it does better
+	slices.SortStableFunc(candidates, func(a, b placement) int {
+		return cmp.Compare(b.score, a.score)
+	})
+
+	chosen := candidates[0]
+	if len(candidates) > 1 && h.rng.Float64() < h.Mistakes {
+		chosen = candidates[1+h.rng.Intn(min(mistake_choices, len(candidates)-1))]
+	}
+
+	return chosen.piece, chosen.hold, true
+}
+
+func (h *Heuristic) Close() error {
+	return nil
+}
+
+// placements scores every spot the piece can reach.
+func (h *Heuristic) placements(g grid, game *engine.Game, piece engine.Piece, hold bool) (placements []placement) {
+	if !g.fits(piece) {
+		return
+	}
+
+	invalid_lines := game.Config().InvalidLines
+	for _, spot := range search(g, piece, game.Kicks).landings(g) {
+		after, lines := g.place(spot.piece)
+		placements = append(placements, placement{
+			piece: spot.piece,
+			hold:  hold,
+			score: after.evaluate(h.Weights, lines, invalid_lines),
+		})
+	}
+
+	return
+}
@@ -0,0 +1,220 @@
+package ai
+
+import (
+	"errors"
+
+	"github.com/bennicholls/tytris/engine"
+	"github.com/bennicholls/tytris/tbp"
+)
+
+// External is a brain that asks an external bot where pieces go, over the Tetris Bot Protocol. If the bot fails it
+// hands over to a fallback brain, so the game can carry on.
+type External struct {
+	Name string // the bot's name, once it has introduced itself
+
+	bot      *tbp.Bot
+	fallback Brain
+	err      error // why the bot failed. once it's set, the fallback takes over
+
+	ready   bool // the bot has accepted the rules
+	started bool // the bot has been told about the game
+
+	dropped   int       // pieces dropped when the bot was last asked for a suggestion. -1 if it hasn't been asked
+	waiting   bool      // waiting for the bot's suggestion
+	move      *tbp.Move // the bot's suggestion for the current piece
+	target    engine.Piece
+	hold      bool
+	expected  grid // what the board should look like once the suggestion is played
+	revealed  int  // pieces the bot has been told about, counting from the start of the game
+	bot_holds bool // whether the bot thinks there's a held piece
+}
+
+// NewExternal launches a bot with the command, which is split on spaces into the program and its arguments.
+func NewExternal(command string, fallback Brain) (*External, error) {
+	bot, err := tbp.Launch(command)
+	if err != nil {
+		return nil, err
+	}
+
+	return &External{bot: bot, fallback: fallback, dropped: -1}, nil
+}
+
+func (e *External) Choose(game *engine.Game, can_hold bool) (target engine.Piece, hold bool, ok bool) {
+	if e.err == nil {
+		e.update(game)
+	}
+
+	if e.err != nil {
+		return e.fallback.Choose(game, can_hold)
+	}
+
+	if !e.started || e.waiting {
+		return
+	}
+
+	// the bot's suggestion came too late, or can't be used now, so the fallback handles this piece
+	if e.move == nil || (e.hold && !can_hold) {
+		return e.fallback.Choose(game, can_hold)
+	}
+
+	return e.target, e.hold, true
+}
+
+// update reads whatever the bot has sent, and keeps it up to date with the game.
+func (e *External) update(game *engine.Game) {
+	for {
+		msg, ok := e.bot.Poll()
+		if !ok {
+			break
+		}
+
+		switch msg.Type {
+		case tbp.MSG_INFO:
+			e.Name = msg.Name
+			e.send(tbp.Message{Type: tbp.MSG_RULES})
+		case tbp.MSG_READY:
+			e.ready = true
+		case tbp.MSG_ERROR:
+			e.fail(errors.New("bot error: " + msg.Reason))
+		case tbp.MSG_SUGGESTION:
+			if e.waiting {
+				e.suggestion(game, msg.Moves)
+			}
+		}
+	}
+
+	if err := e.bot.Err(); err != nil {
+		e.fail(err)
+	}
+
+	if e.err != nil || !e.ready || e.waiting || game.Info().PiecesDropped == e.dropped {
+		return
+	}
+
+	// a new piece, so tell the bot how the last one went and ask about this one
+	switch {
+	case !e.started:
+		if err := tbp.Supports(game.Config()); err != nil {
+			e.fail(err)
+			return
+		}
+		e.start(game)
+		e.started = true
+	case e.move != nil && e.played(game):
+		e.send(tbp.Message{Type: tbp.MSG_PLAY, Move: e.move})
+		e.reveal(game)
+	default: // something the bot didn't see coming happened (garbage, or the piece went somewhere else), so start over
+		e.send(tbp.Message{Type: tbp.MSG_STOP})
+		e.start(game)
+	}
+
+	e.dropped = game.Info().PiecesDropped
+	e.move = nil
+	e.waiting = true
+	e.send(tbp.Message{Type: tbp.MSG_SUGGEST})
+}
+
+// start tells the bot everything about the game as it is now.
+func (e *External) start(game *engine.Game) {
+	e.send(tbp.Start(game))
+	e.revealed = pulled(game) + len(game.UpcomingPieces(engine.UPCOMING_PIECES))
+	e.bot_holds = game.HeldPiece().Type != engine.NO_PIECE
+}
+
+// reveal tells the bot about the pieces that have been added to the queue since it last heard.
+func (e *External) reveal(game *engine.Game) {
+	upcoming := game.UpcomingPieces(engine.UPCOMING_PIECES)
+	first := pulled(game)
+	for i := e.revealed - first; i < len(upcoming); i++ {
+		if upcoming[i].Type != engine.NO_PIECE {
+			e.send(tbp.Message{Type: tbp.MSG_NEW_PIECE, Piece: upcoming[i].Type.Letter()})
+		}
+	}
+	e.revealed = first + len(upcoming)
+}
+
+// pulled counts the pieces that have come out of the queue: every piece dropped, plus the current and held pieces.
+func pulled(game *engine.Game) (count int) {
+	count = game.Info().PiecesDropped + 1
+	if game.HeldPiece().Type != engine.NO_PIECE {
+		count += 1
+	}
+
+	return
+}
+
+// played reports whether the last suggestion was played the way the bot expects, so it can carry on from there.
+func (e *External) played(game *engine.Game) bool {
+	holds := e.bot_holds || e.hold
+	if holds != (game.HeldPiece().Type != engine.NO_PIECE) {
+		return false
+	}
+	e.bot_holds = holds
+
+	board := newGrid(game.Board())
+	for i := range board.cells {
+		if board.cells[i] != e.expected.cells[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// suggestion takes the bot's best move for the current piece.
+func (e *External) suggestion(game *engine.Game, moves []tbp.Move) {
+	e.waiting = false
+	if game.Info().PiecesDropped != e.dropped { // too slow, the piece it was thinking about already locked
+		return
+	}
+
+	if len(moves) == 0 {
+		e.fail(errors.New("bot has no moves"))
+		return
+	}
+
+	height := game.Board().Size().H
+	target, err := tbp.ToPiece(moves[0].Location, height)
+	if err != nil {
+		e.fail(err)
+		return
+	}
+
+	g := newGrid(game.Board())
+	if !g.fits(target) || g.drop(target) != target {
+		e.fail(errors.New("bot suggested a spot the piece can't lock in"))
+		return
+	}
+
+	hold := target.Type != game.CurrentPiece().Type
+	start := game.CurrentPiece()
+	if hold {
+		start = swapPiece(game)
+	}
+	if _, ok := search(g, start, game.Kicks).path(g, target); !ok { // the piece fell past it while the bot was thinking
+		return
+	}
+
+	e.move = &moves[0]
+	e.target = target
+	e.hold = hold
+	e.expected, _ = g.place(target)
+}
+
+func (e *External) send(msg tbp.Message) {
+	if err := e.bot.Send(msg); err != nil {
+		e.fail(err)
+	}
+}
+
+func (e *External) fail(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+// Close shuts the bot down, returning the reason it failed if it did.
+func (e *External) Close() error {
+	e.bot.Close()
+	return e.err
+}
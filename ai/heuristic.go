@@ -0,0 +1,157 @@
+package ai
+
+import (
+	"github.com/bennicholls/tytris/engine"
+	"github.com/bennicholls/tyumi/vec"
+)
+
+// Weights score the board a placement leaves behind. Each feature of the board is multiplied by its weight and the
+// results are added up, so good features get positive weights and bad ones get negative weights.
+type Weights struct {
+	Height    float64 // sum of the heights of every column
+	Lines     float64 // lines cleared by the placement
+	Holes     float64 // empty cells with a block somewhere above them
+	Bumpiness float64 // sum of the differences in height between neighbouring columns
+	Wells     float64 // sum of the depths of the columns that are lower than both of their neighbours
+}
+
+// DefaultWeights play a safe game that keeps the stack low and flat, and survives a long time.
+var DefaultWeights = Weights{
+	Height:    -0.510066,
+	Lines:     0.760666,
+	Holes:     -0.35663,
+	Bumpiness: -0.184483,
+	Wells:     -0.05,
+}
+
+// score for leaving blocks in the invalid lines at the top of the well, which tops out the game
+const top_out_score float64 = -1e6
+
+// grid is a copy of the board that placements can be tried out on.
+type grid struct {
+	size  vec.Dims
+	cells []bool
+}
+
+func newGrid(board *engine.Board) (g grid) {
+	g.size = board.Size()
+	g.cells = make([]bool, g.size.Area())
+	for i := range g.cells {
+		g.cells[i] = board.Block(vec.IndexToCoord(i, g.size.W)) != engine.NO_PIECE
+	}
+
+	return
+}
+
+func (g grid) filled(pos vec.Coord) bool {
+	return g.cells[pos.ToIndex(g.size.W)]
+}
+
+// fits reports whether the piece is inside the well without overlapping any blocks.
+func (g grid) fits(piece engine.Piece) bool {
+	for pos := range piece.EachBlock() {
+		if !pos.IsInside(g.size) || g.filled(pos) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// drop returns the piece moved as far down as it can go.
+func (g grid) drop(piece engine.Piece) engine.Piece {
+	for {
+		below := piece
+		below.Pos = below.Pos.Step(vec.DIR_DOWN)
+		if !g.fits(below) {
+			return piece
+		}
+		piece = below
+	}
+}
+
+// place returns a copy of the grid with the piece locked in and any full lines cleared.
+func (g grid) place(piece engine.Piece) (after grid, lines int) {
+	after = grid{size: g.size, cells: make([]bool, 0, len(g.cells))}
+	placed := make([]bool, len(g.cells))
+	copy(placed, g.cells)
+	for pos := range piece.EachBlock() {
+		placed[pos.ToIndex(g.size.W)] = true
+	}
+
+	// copy the rows that aren't full, bottom up, then pad the top with empty rows
+	for y := g.size.H - 1; y >= 0; y-- {
+		row := placed[y*g.size.W : (y+1)*g.size.W]
+		full := true
+		for _, filled := range row {
+			full = full && filled
+		}
+
+		if full {
+			lines += 1
+		} else {
+			after.cells = append(row[:len(row):len(row)], after.cells...)
+		}
+	}
+	after.cells = append(make([]bool, lines*g.size.W), after.cells...)
+
+	return
+}
+
+// evaluate scores the grid, after a placement that cleared some lines. invalid_lines is the number of lines at the top
+// of the well that tops out the game if anything is left in them.
+func (g grid) evaluate(weights Weights, lines, invalid_lines int) (score float64) {
+	heights := make([]int, g.size.W)
+	holes := 0
+	for x := range g.size.W {
+		for y := range g.size.H {
+			if g.filled(vec.Coord{x, y}) {
+				if heights[x] == 0 {
+					heights[x] = g.size.H - y
+				}
+			} else if heights[x] > 0 {
+				holes += 1
+			}
+		}
+
+		if heights[x] > g.size.H-invalid_lines {
+			return top_out_score
+		}
+	}
+
+	height, bumpiness, wells := 0, 0, 0
+	for x, h := range heights {
+		height += h
+		if x > 0 {
+			bumpiness += abs(h - heights[x-1])
+		}
+
+		// the walls count as neighbours that are as tall as the well
+		left, right := g.size.H, g.size.H
+		if x > 0 {
+			left = heights[x-1]
+		}
+		if x < g.size.W-1 {
+			right = heights[x+1]
+		}
+		if depth := min(left, right) - h; depth > 0 {
+			wells += depth
+		}
+	}
+
+	score += weights.Height * float64(height)
+	score += weights.Lines * float64(lines)
+	score += weights.Holes * float64(holes)
+	score += weights.Bumpiness * float64(bumpiness)
+	score += weights.Wells * float64(wells)
+
+	return
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
@@ -0,0 +1,149 @@
+package ai
+
+import (
+	"github.com/bennicholls/tytris/engine"
+	"github.com/bennicholls/tyumi/vec"
+)
+
+// move is one of the inputs the bot steers pieces with.
+type move int
+
+const (
+	MOVE_NONE move = iota // the piece is where it needs to be, so it can be hard dropped
+	MOVE_LEFT
+	MOVE_RIGHT
+	MOVE_CW
+	MOVE_CCW
+	MOVE_180
+	MOVE_DROP // soft drop all the way to the floor, for tucking pieces under overhangs
+)
+
+// the moves tried when searching, in the order they're tried. sideways moves come first so that, between two paths of
+// the same length, the bot prefers the one that doesn't have to wait for a soft drop.
+var search_moves = []move{MOVE_LEFT, MOVE_RIGHT, MOVE_CW, MOVE_CCW, MOVE_180, MOVE_DROP}
+
+// kickFunc gives the kicks to try for a rotation, like engine.Game.Kicks.
+type kickFunc func(piece engine.PieceType, from, to int) []vec.Coord
+
+// step records how a position was first reached during a search.
+type step struct {
+	from  engine.Piece
+	move  move
+	depth int // number of moves it takes to get here
+}
+
+// reachable is every position a piece can get to from where it started.
+type reachable struct {
+	start engine.Piece
+	steps map[engine.Piece]step
+	order []engine.Piece // every position, in the order the search found them, which is closest first
+}
+
+// search finds every position the piece can be moved to, with a breadth first search over the moves.
+func search(g grid, start engine.Piece, kicks kickFunc) (r reachable) {
+	r.start = start
+	r.steps = map[engine.Piece]step{start: {from: start}}
+	r.order = []engine.Piece{start}
+	for i := 0; i < len(r.order); i++ {
+		piece := r.order[i]
+		for _, m := range search_moves {
+			next, ok := g.apply(piece, m, kicks)
+			if !ok {
+				continue
+			}
+
+			if _, seen := r.steps[next]; !seen {
+				r.steps[next] = step{from: piece, move: m, depth: r.steps[piece].depth + 1}
+				r.order = append(r.order, next)
+			}
+		}
+	}
+
+	return
+}
+
+// landing is a position a piece can be locked in.
+type landing struct {
+	piece engine.Piece
+	moves int // moves it takes to get somewhere the piece can be hard dropped from to land here
+}
+
+// landings returns every position the piece can be locked in, closest first.
+func (r reachable) landings(g grid) (landings []landing) {
+	seen := make(map[engine.Piece]bool)
+	for _, piece := range r.order {
+		if landed := g.drop(piece); !seen[landed] {
+			seen[landed] = true
+			landings = append(landings, landing{landed, r.steps[piece].depth})
+		}
+	}
+
+	return
+}
+
+// path returns the moves that take the piece to a position it can be hard dropped from to land on the target. ok is
+// false if there's no way to get there.
+func (r reachable) path(g grid, target engine.Piece) (moves []move, ok bool) {
+	for _, piece := range r.order {
+		if g.drop(piece) != target {
+			continue
+		}
+
+		for piece != r.start {
+			s := r.steps[piece]
+			moves = append(moves, s.move)
+			piece = s.from
+		}
+
+		// traced back from the end, so flip them around
+		for i, j := 0, len(moves)-1; i < j; i, j = i+1, j-1 {
+			moves[i], moves[j] = moves[j], moves[i]
+		}
+
+		return moves, true
+	}
+
+	return nil, false
+}
+
+// apply makes a move, returning where the piece ends up. ok is false if the move isn't possible.
+func (g grid) apply(piece engine.Piece, m move, kicks kickFunc) (moved engine.Piece, ok bool) {
+	switch m {
+	case MOVE_LEFT:
+		piece.Pos = piece.Pos.Step(vec.DIR_LEFT)
+		return piece, g.fits(piece)
+	case MOVE_RIGHT:
+		piece.Pos = piece.Pos.Step(vec.DIR_RIGHT)
+		return piece, g.fits(piece)
+	case MOVE_DROP:
+		moved = g.drop(piece)
+		return moved, moved != piece
+	case MOVE_CW:
+		return g.rotate(piece, engine.CW, kicks)
+	case MOVE_CCW:
+		return g.rotate(piece, engine.CCW, kicks)
+	case MOVE_180:
+		return g.rotate(piece, engine.ROTATE_180, kicks)
+	}
+
+	return
+}
+
+// rotate rotates the piece the same way the game does, trying each kick until one fits.
+func (g grid) rotate(piece engine.Piece, dir int, kicks kickFunc) (rotated engine.Piece, ok bool) {
+	rotated = piece
+	rotated.Rotate(dir)
+	if rotated.Rotation == piece.Rotation {
+		return // pieces that don't rotate
+	}
+
+	for _, kick := range kicks(piece.Type, piece.Rotation, rotated.Rotation) {
+		test := rotated
+		test.Pos = test.Pos.Add(kick)
+		if g.fits(test) {
+			return test, true
+		}
+	}
+
+	return
+}
@@ -7,6 +7,7 @@ if [ $? -eq "0" ]
 then 
 echo "BUILD COMPLETE"
 mv ../game/game.exe tytris.exe
+env GOOS="windows" go build -C "../tbp/stub" -o "../../build/tbpstub.exe"
 ./tytris.exe
 else echo "POBLEMS, BUILD DID NOT GO WELL"
 fi
\ No newline at end of file
@@ -7,5 +7,6 @@ if [ $? -eq "0" ]
 then 
 echo "BUILD COMPLETE"
 mv ../game/game.exe tytris_release.exe
+env GOOS="windows" go build -C "../tbp/stub" -o "../../build/tbpstub.exe"
 else echo "POBLEMS, BUILD DID NOT GO WELL"
 fi
\ No newline at end of file
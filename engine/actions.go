@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"github.com/bennicholls/tyumi/vec"
+)
+
+// Actions are the inputs a player (or anything else) can give to a game. They are passed to Game.Step() and applied
+// at the start of the tick.
+type Action int
+
+const (
+	ACTION_MOVE_LEFT Action = iota // pressing left. the piece moves once, and then auto-shifts while left is held
+	ACTION_MOVE_RIGHT
+	ACTION_RELEASE_LEFT
+	ACTION_RELEASE_RIGHT
+	ACTION_ROTATE_CW
+	ACTION_ROTATE_CCW
+	ACTION_ROTATE_180
+	ACTION_HARD_DROP
+	ACTION_SOFT_DROP_ON
+	ACTION_SOFT_DROP_OFF
+	ACTION_HOLD
+	ACTION_PAUSE // the game was paused. pausing is up to the frontend, this is only here so replays can show it
+
+	// a line of garbage arrived from an opponent. it doesn't come from the player, but making it an action puts it in
+	// the replay. lines received on the same tick arrive together.
+	ACTION_GARBAGE
+)
+
+func (g *Game) doAction(action Action) {
+	switch action {
+	case ACTION_MOVE_LEFT:
+		g.left_held = true
+		g.startShift(-1)
+	case ACTION_MOVE_RIGHT:
+		g.right_held = true
+		g.startShift(1)
+	case ACTION_RELEASE_LEFT:
+		g.left_held = false
+		g.stopShift(-1)
+	case ACTION_RELEASE_RIGHT:
+		g.right_held = false
+		g.stopShift(1)
+	case ACTION_ROTATE_CW:
+		g.rotatePiece(CW)
+	case ACTION_ROTATE_CCW:
+		g.rotatePiece(CCW)
+	case ACTION_ROTATE_180:
+		g.rotatePiece(ROTATE_180)
+	case ACTION_HARD_DROP:
+		g.dropPiece()
+	case ACTION_SOFT_DROP_ON:
+		g.speed_up = true
+	case ACTION_SOFT_DROP_OFF:
+		g.speed_up = false
+	case ACTION_HOLD:
+		g.swap_held_piece()
+	case ACTION_GARBAGE:
+		g.receiveGarbage(1)
+	}
+}
+
+func (g *Game) rotatePiece(dir int) {
+	if g.current_piece.Type == NO_PIECE {
+		return
+	}
+
+	kick, kick_index, ok := g.testRotate(dir)
+	if !ok {
+		return
+	}
+
+	g.current_piece.Rotate(dir)
+	g.current_piece.Pos.Move(kick.X, kick.Y)
+	g.last_move_was_rotation = true
+	g.last_kick_index = kick_index
+	g.last_rotation = dir
+	g.resetLockTimer()
+	g.updateGhost()
+
+	g.fireEvent(Event{Type: EV_PIECE_ROTATED, Piece: g.current_piece})
+}
+
+func (g *Game) movePiece(dir vec.Direction) {
+	if g.current_piece.Type == NO_PIECE {
+		return
+	}
+
+	if !g.testMove(dir) {
+		return
+	}
+
+	g.current_piece.Pos.Move(dir.X, dir.Y)
+	g.last_move_was_rotation = false
+	g.resetLockTimer()
+	g.updateGhost()
+
+	g.fireEvent(Event{Type: EV_PIECE_MOVED, Piece: g.current_piece, Dir: dir})
+}
+
+func (g *Game) dropPiece() {
+	if g.current_piece.Type == NO_PIECE {
+		return
+	}
+
+	if cells := g.ghost_position.Y - g.current_piece.Pos.Y; cells > 0 {
+		g.last_move_was_rotation = false
+		g.addDropPoints(cells, true)
+	}
+
+	g.current_piece.Pos = g.ghost_position
+	g.lockPiece(true)
+	g.das_cut_timer = g.config.Handling.DASCut
+	g.info.QuickDrops += 1
+}
+
+func (g *Game) swap_held_piece() {
+	if g.current_piece.Type == NO_PIECE {
+		return
+	}
+
+	if g.held_piece.Type == g.current_piece.Type {
+		return
+	}
+
+	if g.swapped_piece {
+		return
+	}
+
+	if g.held_piece.Type == NO_PIECE {
+		if g.upcoming_pieces[0].Type == NO_PIECE {
+			return // nothing left to swap in
+		}
+		g.held_piece = g.config.Pieces.newPiece(g.current_piece.Type)
+		g.spawn_piece(g.get_next_piece())
+	} else {
+		held := g.held_piece
+		g.held_piece = g.config.Pieces.newPiece(g.current_piece.Type)
+		g.spawn_piece(held)
+	}
+
+	g.swapped_piece = true
+	g.fireEvent(Event{Type: EV_PIECE_HELD, Piece: g.held_piece})
+
+	g.info.Swaps += 1
+}
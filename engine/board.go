@@ -0,0 +1,240 @@
+package engine
+
+import (
+	"slices"
+
+	"github.com/bennicholls/tyumi/vec"
+)
+
+// Board is the well that pieces fall into, holding all of the blocks that have been locked in place.
+type Board struct {
+	lines []Line
+	size  vec.Dims
+}
+
+func (b *Board) Init(size vec.Dims) {
+	b.size = size
+	b.lines = make([]Line, size.H)
+	for i := range b.lines {
+		b.lines[i].blocks = make([]PieceType, size.W)
+		b.lines[i].locked = make([]int, size.W)
+	}
+	b.Clear()
+}
+
+func (b *Board) Clear() {
+	for i := range b.lines {
+		b.lines[i].Clear()
+	}
+}
+
+func (b Board) Size() vec.Dims {
+	return b.size
+}
+
+// Block returns the type of the block at pos, or NO_PIECE if the cell is empty.
+func (b Board) Block(pos vec.Coord) PieceType {
+	return b.lines[pos.Y].blocks[pos.X]
+}
+
+// LockTime returns the tick the block at pos was locked in place (or rose up from the bottom, for garbage). Blocks that
+// were there from the start of the game were locked at tick 0.
+func (b Board) LockTime(pos vec.Coord) int {
+	return b.lines[pos.Y].locked[pos.X]
+}
+
+// IsValidPosition reports whether the piece fits in the well without overlapping any locked blocks.
+func (b Board) IsValidPosition(piece Piece) bool {
+	for block_pos := range piece.EachBlock() {
+		//not in well
+		if !block_pos.IsInside(b.size) {
+			return false
+		}
+
+		//collide with matrix
+		if b.lines[block_pos.Y].blocks[block_pos.X] != NO_PIECE {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Lock writes the piece into the board at its current position, remembering the tick it was locked at.
+func (b *Board) Lock(piece Piece, time int) {
+	for block_pos := range piece.EachBlock() {
+		b.lines[block_pos.Y].blocks[block_pos.X] = piece.Type
+		b.lines[block_pos.Y].locked[block_pos.X] = time
+	}
+}
+
+// FullLines returns the indices of all lines that are full and waiting to be cleared.
+func (b Board) FullLines() (lines []int) {
+	for i, line := range b.lines {
+		if line.isFull() {
+			lines = append(lines, i)
+		}
+	}
+
+	return
+}
+
+// HasBlockAbove reports whether any of the top n lines of the board contain a block.
+func (b Board) HasBlockAbove(n int) bool {
+	for i := range n {
+		if b.lines[i].hasBlock() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Clean destroys all full lines, moving the lines above them down to fill the gaps. Returns the number of lines
+// destroyed.
+func (b *Board) Clean() (destroyed int) {
+	for i, line := range b.lines {
+		if line.isFull() {
+			b.destroyLine(i)
+			destroyed += 1
+		}
+	}
+
+	return
+}
+
+// Load fills the bottom of the board with rows of blocks, as described by ParseBoardRow(). The first row is the
+// highest. Rows that are too wide for the board are cut short, and invalid rows are left empty.
+func (b *Board) Load(rows []string) {
+	b.Clear()
+	for i, row := range rows {
+		y := b.size.H - len(rows) + i
+		if y < 0 {
+			continue
+		}
+
+		blocks, err := ParseBoardRow(row)
+		if err != nil {
+			continue
+		}
+		copy(b.lines[y].blocks, blocks)
+	}
+}
+
+// Rows describes the board as rows of text in the format Load() reads, top row first. Standard pieces are written as
+// their letter, and garbage and custom pieces as '#'.
+func (b Board) Rows() (rows []string) {
+	letters := make(map[PieceType]byte)
+	for letter, piece := range pieceLetters {
+		letters[piece] = byte(letter)
+	}
+
+	for _, line := range b.lines {
+		row := make([]byte, len(line.blocks))
+		for i, block := range line.blocks {
+			switch letter, ok := letters[block]; {
+			case block == NO_PIECE:
+				row[i] = '.'
+			case ok:
+				row[i] = letter
+			default:
+				row[i] = '#'
+			}
+		}
+		rows = append(rows, string(row))
+	}
+
+	return
+}
+
+// InsertGarbage pushes the whole board up one row and adds a row of garbage at the bottom, with a hole in the provided
+// column. The garbage counts as locked at the provided tick. Returns true if blocks were pushed out of the top of the
+// board.
+func (b *Board) InsertGarbage(hole, time int) (overflow bool) {
+	overflow = b.lines[0].hasBlock()
+
+	// recycle the top line as the new bottom one
+	top := b.lines[0]
+	copy(b.lines, b.lines[1:])
+	b.lines[len(b.lines)-1] = top
+
+	for i := range top.blocks {
+		if i == hole {
+			top.blocks[i] = NO_PIECE
+		} else {
+			top.blocks[i] = GARBAGE
+		}
+		top.locked[i] = time
+	}
+
+	return
+}
+
+// GarbageLines returns the number of lines with garbage in them.
+func (b Board) GarbageLines() (count int) {
+	for _, line := range b.lines {
+		if line.hasGarbage() {
+			count += 1
+		}
+	}
+
+	return
+}
+
+// isEmptyAfterClear reports whether the board would be completely empty once its full lines are cleared.
+func (b Board) isEmptyAfterClear() bool {
+	for _, line := range b.lines {
+		if line.hasBlock() && !line.isFull() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (b *Board) destroyLine(line_index int) {
+	for i := line_index - 1; i > 0; i-- {
+		if b.lines[i].hasBlock() {
+			copy(b.lines[i+1].blocks, b.lines[i].blocks)
+			copy(b.lines[i+1].locked, b.lines[i].locked)
+		} else {
+			b.lines[i+1].Clear()
+			break
+		}
+	}
+}
+
+type Line struct {
+	blocks []PieceType
+	locked []int // tick each block was locked at
+}
+
+func (l *Line) Clear() {
+	for i := range l.blocks {
+		l.blocks[i] = NO_PIECE
+		l.locked[i] = 0
+	}
+}
+
+func (l Line) isFull() bool {
+	for _, block := range l.blocks {
+		if block == NO_PIECE {
+			return false
+		}
+	}
+	return true
+}
+
+func (l Line) hasGarbage() bool {
+	return slices.Contains(l.blocks, GARBAGE)
+}
+
+func (l Line) hasBlock() bool {
+	for _, block := range l.blocks {
+		if block != NO_PIECE {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/bennicholls/tyumi/vec"
+)
+
+// testBoard makes a 4 wide, 6 high board with rows loaded at the bottom.
+func testBoard(rows ...string) (b Board) {
+	b.Init(vec.Dims{4, 6})
+	b.Load(rows)
+	return
+}
+
+func TestDestroyLine(t *testing.T) {
+	tests := []struct {
+		name string
+		rows []string
+		line int
+		want []string
+	}{
+		{"bottom line", []string{"IJ..", "LLLL"}, 5, []string{"....", "....", "....", "....", "....", "IJ.."}},
+		{"middle line", []string{"I...", "OOOO", "SS.."}, 4, []string{"....", "....", "....", "....", "I...", "SS.."}},
+		{"lines above shift down", []string{"T...", "Z...", "JJJJ"}, 5, []string{"....", "....", "....", "....", "T...", "Z..."}},
+		{"only line", []string{"IIII"}, 5, []string{"....", "....", "....", "....", "....", "...."}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := testBoard(test.rows...)
+			b.destroyLine(test.line)
+			if got := b.Rows(); !slices.Equal(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestClean(t *testing.T) {
+	b := testBoard("T...", "IIII", "S.SS", "OOOO")
+	if lines := b.FullLines(); !slices.Equal(lines, []int{3, 5}) {
+		t.Errorf("full lines are %v, want [3 5]", lines)
+	}
+
+	if destroyed := b.Clean(); destroyed != 2 {
+		t.Errorf("destroyed %d lines, want 2", destroyed)
+	}
+
+	want := []string{"....", "....", "....", "....", "T...", "S.SS"}
+	if got := b.Rows(); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if lines := b.FullLines(); len(lines) != 0 {
+		t.Errorf("full lines left after cleaning: %v", lines)
+	}
+}
+
+func TestLock(t *testing.T) {
+	b := testBoard("J...")
+	piece := Piece{Type: T, Pos: vec.Coord{1, 3}}
+	if !b.IsValidPosition(piece) {
+		t.Fatal("piece doesn't fit an empty spot")
+	}
+
+	b.Lock(piece, 7)
+	want := []string{"....", "....", "....", "..T.", ".TTT", "J..."}
+	if got := b.Rows(); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if b.IsValidPosition(piece) {
+		t.Error("piece fits on top of itself after locking")
+	}
+	if time := b.LockTime(vec.Coord{2, 3}); time != 7 {
+		t.Errorf("block locked at tick %d, want 7", time)
+	}
+	if time := b.LockTime(vec.Coord{0, 5}); time != 0 {
+		t.Errorf("block from the start locked at tick %d, want 0", time)
+	}
+}
@@ -0,0 +1,54 @@
+package engine
+
+// DelayStep sets the delays between pieces from a level onwards.
+type DelayStep struct {
+	Level     int
+	ARE       int // ticks between a piece locking and the next piece spawning
+	LineClear int // extra ticks to wait before the next piece when lines are cleared, while the stack comes down
+	LockDelay int // replaces Config.LockDelay from this level on. 0 leaves it alone
+}
+
+// DelayCurve lists the delays between pieces as the level goes up, in order of level. An empty curve has no delays,
+// so the next piece spawns on the tick after the last one locks.
+type DelayCurve []DelayStep
+
+// At returns the delays for the provided level.
+func (dc DelayCurve) At(level int) (step DelayStep) {
+	for _, s := range dc {
+		if s.Level > level {
+			break
+		}
+		step = s
+	}
+
+	return
+}
+
+// Delays from TGM2's master mode. They start long and get shorter every hundred levels past 500, with lock delay
+// shrinking for the final section.
+var MasterDelays = DelayCurve{
+	{Level: 0, ARE: 25, LineClear: 40},
+	{Level: 500, ARE: 25, LineClear: 25},
+	{Level: 600, ARE: 25, LineClear: 16},
+	{Level: 700, ARE: 16, LineClear: 12},
+	{Level: 800, ARE: 12, LineClear: 6},
+	{Level: 900, ARE: 12, LineClear: 6, LockDelay: 17},
+}
+
+// startSpawnDelay starts the wait for the next piece after one locks.
+func (g *Game) startSpawnDelay(lines_cleared bool) {
+	delays := g.config.Delays.At(g.info.Level)
+	g.spawn_delay = delays.ARE
+	if lines_cleared {
+		g.spawn_delay += delays.LineClear
+	}
+}
+
+// lockDelay returns the number of ticks a piece can rest on the stack at the current level.
+func (g *Game) lockDelay() int {
+	if delay := g.config.Delays.At(g.info.Level).LockDelay; delay > 0 {
+		return delay
+	}
+
+	return g.config.LockDelay
+}
@@ -0,0 +1,46 @@
+package engine
+
+import (
+	"github.com/bennicholls/tyumi/vec"
+)
+
+type EventType int
+
+const (
+	EV_PIECE_SPAWNED   EventType = iota // a new piece has entered the well
+	EV_PIECE_MOVED                      // the current piece moved one cell in Event.Dir
+	EV_PIECE_ROTATED                    // the current piece was rotated
+	EV_PIECE_LOCKED                     // the current piece was locked into the board
+	EV_PIECE_HELD                       // the current piece was swapped with the held piece
+	EV_LINES_DESTROYED                  // full lines were removed from the board and the lines above shifted down
+	EV_SCORE                            // points were scored
+	EV_GRAVITY_CHANGED                  // the game sped up
+	EV_LEVEL_UP                         // a new level was reached
+	EV_TOP_OUT                          // the stack reached the top of the well. game over!
+	EV_GOAL_REACHED                     // the game's goal was reached. the game is over (in a good way) unless it plays past the goal
+	EV_TIME_UP                          // the time limit ran out. the game is over
+	EV_GARBAGE_RISEN                    // garbage rose up from the bottom of the well, pushing the stack (and maybe the piece) up
+	EV_OUT_OF_PIECES                    // the pieces ran out before the objective was completed. game over!
+	EV_ATTACK                           // a clear sent garbage to the opponent, after cancelling any incoming garbage
+)
+
+// Events are produced by Game.Step() to let a frontend know what happened during the tick, so it can update its
+// visuals, play sounds, etc. Only the fields relevant to the event's type are filled in.
+type Event struct {
+	Type EventType
+
+	Piece  Piece         // the piece involved in the event
+	Dir    vec.Direction // direction moved, for EV_PIECE_MOVED
+	Lines  []int         // indices of lines completed by locking a piece, for EV_PIECE_LOCKED
+	TSpin  TSpin         // whether the piece was locked with a t-spin, for EV_PIECE_LOCKED
+	Points int           // points scored, for EV_SCORE
+	Attack int           // lines of garbage sent, for EV_ATTACK
+
+	// for EV_PIECE_LOCKED, whether the piece was hard dropped. for EV_SCORE, whether the points were for dropping
+	// a piece rather than clearing lines.
+	Dropped bool
+}
+
+func (g *Game) fireEvent(e Event) {
+	g.events = append(g.events, e)
+}
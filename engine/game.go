@@ -0,0 +1,584 @@
+// Package engine implements the rules of TyTris: the well, falling pieces, gravity, line clears and scoring. It
+// knows nothing about the UI, audio or the platform, so games can be run, tested and simulated headlessly. A frontend
+// drives a Game by calling Step() once per tick and reacting to the events it returns.
+package engine
+
+import (
+	"math/rand"
+	"slices"
+
+	"github.com/bennicholls/tyumi/vec"
+)
+
+// Config holds the tunable rules for a game.
+type Config struct {
+	WellSize       vec.Dims
+	InvalidLines   int // number of lines at the top of the well. if blocks are here when a piece spawns, game over
+	Gravity        GravityCurve
+	LinesPerLevel  int // number of lines to clear to advance a level. 0 means the level never changes
+	MaxLevel       int // the level stops going up once it gets here. 0 means there's no cap
+	Levelling      LevelSystem
+	LevelGoal      int // the game is won once this level is reached. 0 means there is no goal
+	Delays         DelayCurve
+	LockDelay      int // number of ticks a piece can rest on the stack before locking. 0 locks on the next gravity tick
+	LockReset      LockResetMode
+	MaxLockResets  int // for LOCK_RESET_MOVE
+	RotationSystem RotationSystem
+	Scoring        ScoringSystem
+	Handling       Handling
+	Randomizer     RandomizerMaker
+	Pieces         PieceSet // the pieces the randomizer deals out
+	Seed           int64    // seed for the game's random numbers. 0 picks a random seed
+	LineGoal       int      // the game is won once this many lines are cleared. 0 means there is no goal
+	PlayPastGoal   bool     // if true, the game carries on after its goal is reached instead of ending
+	TimeLimit      int      // the game ends after this many ticks. 0 means no time limit
+	Garbage        Garbage
+	Setup          *Setup      // fixed starting position. nil starts with an empty well and pieces from the randomizer
+	Objective      Objective   // the game is won once the objective is complete, and lost if it runs out of pieces first
+	Attack         AttackTable // garbage sent to an opponent for clears, in versus games
+}
+
+func DefaultConfig() Config {
+	return Config{
+		WellSize:       vec.Dims{10, 25},
+		InvalidLines:   3,
+		Gravity:        GuidelineGravity,
+		LinesPerLevel:  10,
+		LockDelay:      30,
+		LockReset:      LOCK_RESET_MOVE,
+		MaxLockResets:  15,
+		RotationSystem: SRS{},
+		Scoring:        GuidelineScoring,
+		Handling:       DefaultHandling(),
+		Randomizer:     BagRandomizer(1),
+		Pieces:         StandardPieces,
+	}
+}
+
+type Game struct {
+	config Config
+	board  Board
+
+	current_piece   Piece
+	held_piece      Piece
+	ghost_position  vec.Coord
+	upcoming_pieces []Piece
+	randomizer      Randomizer
+
+	info                Info
+	gravity             int    // in 1/ROWths of a row per tick
+	gravity_accumulator int    // fraction of a row the current piece has fallen towards the next one
+	speed_up            bool   // will be true if player is holding down the DOWN key
+	swapped_piece       bool   // whether or not a swap has taken place for this piece
+	spawn_next          bool   // true if a new piece needs to be spawned
+	spawn_delay         int    // ticks left to wait before the next piece spawns
+	ending              Ending // how the game ended, ENDING_NONE while it's still going
+	goal_reached        bool   // whether the game's goal has been reached
+	combo               int    // number of consecutive line clears, minus one. -1 if the last piece didn't clear anything
+	back_to_back        bool   // whether the last clear was a difficult one, so the next difficult clear gets a bonus
+
+	last_move_was_rotation bool // whether the last successful action on the current piece was a rotation
+	last_kick_index        int  // index of the kick used by the last successful rotation
+	last_rotation          int  // direction of the last successful rotation: CW, CCW or ROTATE_180
+
+	lock_timer  int // ticks the current piece has been resting on the stack
+	lock_resets int // number of times the lock timer has been reset for the current piece
+	lowest_row  int // lowest row the current piece has reached
+
+	left_held     bool
+	right_held    bool
+	shift_dir     int // direction being auto-shifted. -1 for left, 1 for right, 0 for none
+	das_timer     int // ticks the shift direction has been held for
+	arr_timer     int // ticks since the last auto-shifted move
+	das_cut_timer int // ticks until auto-shift can move the piece after a hard drop
+
+	garbage_rng   *rand.Rand
+	garbage_hole  int // column of the hole in the last row of garbage
+	garbage_timer int // ticks since garbage last rose
+
+	incoming_garbage []int // lines of garbage sent by an opponent and waiting to rise, one entry per attack
+	incoming_tick    int   // tick the last incoming garbage was received on
+
+	replay Replay // recording of every action applied to the game
+	events []Event
+}
+
+// Init prepares a new game with the provided rules. Can also be used to reset a game in progress.
+func (g *Game) Init(config Config) {
+	g.config = config
+	if len(g.config.Pieces.Pieces) == 0 {
+		g.config.Pieces = StandardPieces
+	}
+	g.board.Init(config.WellSize)
+	g.info = Info{Level: 1, Seed: config.Seed}
+	if config.Levelling == LEVEL_TGM {
+		g.info.Level = 0
+	}
+	if g.info.Seed == 0 {
+		g.info.Seed = rand.Int63n(1e9)
+	}
+
+	if config.Setup != nil {
+		g.randomizer = QueueRandomizer(config.Setup.Queue)(nil, nil)
+	} else {
+		g.randomizer = config.Randomizer(rand.New(rand.NewSource(g.info.Seed)), g.config.Pieces.Pieces)
+	}
+	g.initGarbage()
+	g.incoming_garbage = g.incoming_garbage[:0]
+	g.current_piece = Piece{Type: NO_PIECE}
+	g.held_piece = Piece{Type: NO_PIECE}
+	g.initSetup()
+	g.upcoming_pieces = nil
+	g.fill_upcoming()
+	g.gravity = config.Gravity.Gravity(g.info.Level, 0)
+	g.speed_up = false
+	g.left_held, g.right_held = false, false
+	g.shift_dir = 0
+	g.das_cut_timer = 0
+	g.combo = -1
+	g.back_to_back = false
+	g.spawn_next = true
+	g.spawn_delay = 0
+	g.ending = ENDING_NONE
+	g.goal_reached = false
+	g.replay = Replay{}
+}
+
+// Step advances the game by one tick, after applying the provided actions. Returns the events that happened during
+// the tick. The returned slice is only valid until the next call to Step().
+func (g *Game) Step(actions []Action) []Event {
+	g.events = g.events[:0]
+	if g.IsOver() {
+		return g.events
+	}
+
+	g.replay.record(g.info.Time, actions)
+
+	if g.spawn_next && g.spawn_delay > 0 {
+		g.spawn_delay -= 1
+	} else if g.spawn_next {
+		//test for game over
+		if g.board.HasBlockAbove(g.config.InvalidLines) {
+			g.ending = ENDING_TOP_OUT
+			g.fireEvent(Event{Type: EV_TOP_OUT})
+			return g.events
+		}
+
+		if g.board.Clean() > 0 {
+			g.fireEvent(Event{Type: EV_LINES_DESTROYED})
+		}
+
+		if g.isOutOfPieces() {
+			g.ending = ENDING_OUT_OF_PIECES
+			g.fireEvent(Event{Type: EV_OUT_OF_PIECES})
+			return g.events
+		}
+
+		g.spawn_piece(g.get_next_piece())
+		g.spawn_next = false
+	}
+
+	g.updateGarbage()
+	if g.IsOver() {
+		return g.events
+	}
+
+	for _, action := range actions {
+		g.doAction(action)
+	}
+
+	g.updateAutoShift()
+
+	if g.current_piece.Type != NO_PIECE {
+		gravity_tick := g.applyGravity()
+		g.updateLockDelay(gravity_tick)
+	}
+
+	g.info.Time += 1
+
+	if g.config.TimeLimit > 0 && g.info.Time >= g.config.TimeLimit && !g.IsOver() {
+		g.ending = ENDING_TIME_UP
+		g.fireEvent(Event{Type: EV_TIME_UP})
+	}
+
+	return g.events
+}
+
+func (g *Game) Config() Config {
+	return g.config
+}
+
+func (g *Game) Board() *Board {
+	return &g.board
+}
+
+func (g *Game) Info() Info {
+	return g.info
+}
+
+// LinesToNextLevel returns the number of lines that need to be cleared to reach the next level, or 0 if the level
+// never changes (or doesn't go up by lines).
+func (g *Game) LinesToNextLevel() int {
+	if g.config.Levelling != LEVEL_LINES || g.config.LinesPerLevel == 0 || g.info.Level == g.config.MaxLevel {
+		return 0
+	}
+
+	return g.info.Level*g.config.LinesPerLevel - g.info.LinesDestroyed
+}
+
+// LinesToGoal returns the number of lines left to clear to reach the line goal, or 0 if there is no goal.
+func (g *Game) LinesToGoal() int {
+	if g.config.LineGoal == 0 {
+		return 0
+	}
+
+	return max(g.config.LineGoal-g.info.LinesDestroyed, 0)
+}
+
+// TimeRemaining returns the number of ticks left before the time limit runs out, or 0 if there is no limit.
+func (g *Game) TimeRemaining() int {
+	if g.config.TimeLimit == 0 {
+		return 0
+	}
+
+	return max(g.config.TimeLimit-g.info.Time, 0)
+}
+
+// isGoalReached checks the game's goals, if it has any.
+func (g *Game) isGoalReached() bool {
+	if g.config.LineGoal > 0 && g.info.LinesDestroyed >= g.config.LineGoal {
+		return true
+	}
+
+	if g.config.Garbage.Goal > 0 && g.info.GarbageCleared >= g.config.Garbage.Goal {
+		return true
+	}
+
+	if g.config.LevelGoal > 0 && g.info.Level >= g.config.LevelGoal {
+		return true
+	}
+
+	if g.config.Objective.isComplete(g.info, g.board) {
+		return true
+	}
+
+	return false
+}
+
+// GoalReached reports whether the game's goal has been reached. The game might still be going if it's set to play past
+// the goal.
+func (g *Game) GoalReached() bool {
+	return g.goal_reached
+}
+
+func (g *Game) IsOver() bool {
+	return g.ending != ENDING_NONE
+}
+
+func (g *Game) Ending() Ending {
+	return g.ending
+}
+
+// CurrentPiece returns the falling piece. Its type will be NO_PIECE if there isn't one.
+func (g *Game) CurrentPiece() Piece {
+	return g.current_piece
+}
+
+// Ghost returns the current piece, moved to the position it would land at if hard dropped.
+func (g *Game) Ghost() Piece {
+	ghost := g.current_piece
+	ghost.Pos = g.ghost_position
+	return ghost
+}
+
+func (g *Game) HeldPiece() Piece {
+	return g.held_piece
+}
+
+// Combo returns the number of pieces in a row that have cleared lines, counting the last one. 0 if the last piece
+// didn't clear anything.
+func (g *Game) Combo() int {
+	return g.combo + 1
+}
+
+// BackToBack reports whether the last clear was a difficult one, so the next difficult clear gets a bonus.
+func (g *Game) BackToBack() bool {
+	return g.back_to_back
+}
+
+// UpcomingPieces returns the next n pieces that will be spawned.
+func (g *Game) UpcomingPieces(n int) []Piece {
+	return g.upcoming_pieces[0:min(n, len(g.upcoming_pieces))]
+}
+
+// testRotate tries to rotate the current piece, returning the kick that needs to be applied and its index in the
+// rotation system's list of kicks.
+func (g *Game) testRotate(dir int) (kick vec.Coord, kick_index int, ok bool) {
+	test_piece := g.current_piece
+	test_piece.Rotate(dir)
+
+	for i, test_kick := range g.Kicks(test_piece.Type, g.current_piece.Rotation, test_piece.Rotation) {
+		test_piece.Pos.Move(test_kick.X, test_kick.Y)
+		if g.board.IsValidPosition(test_piece) {
+			return test_kick, i, true
+		}
+		test_piece.Pos = test_piece.Pos.Subtract(test_kick)
+	}
+
+	return
+}
+
+func (g *Game) testMove(dir vec.Direction) bool {
+	test_piece := g.current_piece
+	test_piece.Pos = test_piece.Pos.Step(dir)
+	return g.board.IsValidPosition(test_piece)
+}
+
+func (g *Game) lockPiece(dropped bool) {
+	tspin := g.detectTSpin()
+	g.board.Lock(g.current_piece, g.info.Time)
+	locked := g.current_piece
+	g.current_piece.Type = NO_PIECE
+
+	//test for full lines. these are destroyed when the next piece spawns, giving the frontend a chance to animate
+	lines := g.board.FullLines()
+	if len(lines) > 0 {
+		g.info.LinesDestroyed += len(lines)
+		switch len(lines) {
+		case 2:
+			g.info.DoubleKills += 1
+		case 3:
+			g.info.TripleKills += 1
+		case 4:
+			g.info.QuadKills += 1
+		}
+
+		for _, line := range lines {
+			if g.board.lines[line].hasGarbage() {
+				g.info.GarbageCleared += 1
+			}
+		}
+	}
+
+	switch tspin {
+	case TSPIN_FULL:
+		g.info.TSpins += 1
+		switch len(lines) {
+		case 1:
+			g.info.TSpinSingles += 1
+		case 2:
+			g.info.TSpinDoubles += 1
+		case 3:
+			g.info.TSpinTriples += 1
+		}
+	case TSPIN_MINI:
+		g.info.TSpinMinis += 1
+	}
+
+	g.fireEvent(Event{Type: EV_PIECE_LOCKED, Piece: locked, Lines: lines, Dropped: dropped, TSpin: tspin})
+	clear := g.updateScore(len(lines), tspin)
+
+	g.updateLevel(len(lines))
+
+	g.info.PiecesDropped += 1
+	g.spawn_next = true
+	g.startSpawnDelay(len(lines) > 0)
+
+	if g.isGoalReached() && !g.goal_reached {
+		g.goal_reached = true
+		if !g.config.PlayPastGoal {
+			g.ending = ENDING_GOAL
+		}
+		g.fireEvent(Event{Type: EV_GOAL_REACHED})
+	}
+
+	if !g.IsOver() {
+		g.updateAttack(clear, len(lines) > 0 && g.board.isEmptyAfterClear())
+	}
+}
+
+// updateLevel raises the level after a piece locks, clearing some number of lines.
+func (g *Game) updateLevel(lines_destroyed int) {
+	level := g.info.Level
+	switch g.config.Levelling {
+	case LEVEL_LINES:
+		if lines_destroyed == 0 || g.config.LinesPerLevel == 0 {
+			return
+		}
+		level = 1 + g.info.LinesDestroyed/g.config.LinesPerLevel
+	case LEVEL_TGM:
+		if lines_destroyed > 0 {
+			level += lines_destroyed
+		} else if level%100 != 99 && (g.config.MaxLevel == 0 || level < g.config.MaxLevel-1) {
+			level += 1
+		}
+	}
+
+	if g.config.MaxLevel > 0 {
+		level = min(level, g.config.MaxLevel)
+	}
+
+	if level <= g.info.Level {
+		return
+	}
+
+	old_level := g.info.Level
+	g.info.Level = level
+
+	// tgm levels go up with every piece, so only crossing into a new hundred is worth mentioning
+	if g.config.Levelling != LEVEL_TGM || level/100 > old_level/100 {
+		g.fireEvent(Event{Type: EV_LEVEL_UP})
+	}
+}
+
+// updateScore awards points for a locked piece, keeping track of combos and back-to-back clears. Returns the clear
+// the points were awarded for.
+func (g *Game) updateScore(lines_destroyed int, tspin TSpin) (clear Clear) {
+	if lines_destroyed == 0 {
+		g.combo = -1
+		if tspin == TSPIN_NONE {
+			return
+		}
+	} else {
+		g.combo += 1
+		g.info.MaxCombo = max(g.info.MaxCombo, g.combo)
+	}
+
+	clear = Clear{
+		Lines: lines_destroyed,
+		TSpin: tspin,
+		Level: g.info.Level,
+		Combo: max(g.combo, 0),
+	}
+
+	if lines_destroyed > 0 {
+		if clear.IsDifficult() {
+			if g.back_to_back {
+				clear.BackToBack = true
+				g.info.BackToBacks += 1
+			}
+			g.back_to_back = true
+		} else {
+			g.back_to_back = false
+		}
+	}
+
+	points := g.config.Scoring.ClearPoints(clear)
+	if points == 0 {
+		return
+	}
+
+	g.info.Score += points
+	g.fireEvent(Event{Type: EV_SCORE, Points: points})
+
+	return
+}
+
+// addDropPoints awards points for soft or hard dropping the current piece by some number of cells.
+func (g *Game) addDropPoints(cells int, hard_drop bool) {
+	points := g.config.Scoring.DropPoints(cells, hard_drop)
+	if points == 0 {
+		return
+	}
+
+	g.info.Score += points
+	g.fireEvent(Event{Type: EV_SCORE, Points: points, Dropped: true})
+}
+
+func (g *Game) updateGhost() {
+	test_piece := g.current_piece
+	for g.board.IsValidPosition(test_piece) {
+		test_piece.Pos = test_piece.Pos.Step(vec.DIR_DOWN)
+	}
+
+	g.ghost_position = test_piece.Pos.Step(vec.DIR_UP)
+}
+
+// number of pieces to keep in the upcoming piece list
+const UPCOMING_PIECES int = 7
+
+// tops up the upcoming piece list with pieces from the randomizer
+func (g *Game) fill_upcoming() {
+	for len(g.upcoming_pieces) < UPCOMING_PIECES {
+		g.upcoming_pieces = append(g.upcoming_pieces, g.config.Pieces.newPiece(g.randomizer.Next()))
+	}
+}
+
+func (g *Game) spawn_piece(piece Piece) {
+	g.current_piece = piece
+	g.current_piece.Pos = piece.StartLocation(g.board.Size().W)
+	g.swapped_piece = false
+	g.last_move_was_rotation = false
+	if !g.config.Handling.CarryDAS {
+		g.das_timer = 0
+		g.arr_timer = g.config.Handling.ARR - 1
+	}
+	g.lock_timer = 0
+	g.lock_resets = 0
+	g.lowest_row = g.current_piece.Pos.Y
+	g.updateGhost()
+
+	g.fireEvent(Event{Type: EV_PIECE_SPAWNED, Piece: g.current_piece})
+
+	//update gravity if necessary. new pieces fall one row immediately.
+	g.updateGravity()
+	g.gravity_accumulator = max(ROW-g.gravity, 0)
+}
+
+func (g *Game) get_next_piece() Piece {
+	// once a fixed queue runs out, the held piece gets played last
+	if g.upcoming_pieces[0].Type == NO_PIECE && g.held_piece.Type != NO_PIECE {
+		piece := g.held_piece
+		g.held_piece = Piece{Type: NO_PIECE}
+		return piece
+	}
+
+	piece := g.upcoming_pieces[0]
+	g.upcoming_pieces = slices.Delete(g.upcoming_pieces, 0, 1)
+	g.fill_upcoming()
+
+	return piece
+}
+
+// LevelSystem decides how the level goes up.
+type LevelSystem int
+
+const (
+	LEVEL_LINES LevelSystem = iota // the level goes up every Config.LinesPerLevel lines cleared, starting from 1
+	LEVEL_TGM                      // TGM style. starting from 0, the level goes up for every piece and every line cleared, but only lines can take it past 99, 199, etc. or the level before MaxLevel
+)
+
+// Ending describes how a game ended.
+type Ending int
+
+const (
+	ENDING_NONE          Ending = iota // the game isn't over
+	ENDING_TOP_OUT                     // the stack reached the top of the well
+	ENDING_GOAL                        // the goal was reached
+	ENDING_TIME_UP                     // the time limit ran out
+	ENDING_OUT_OF_PIECES               // the pieces ran out before the objective was completed
+)
+
+type Info struct {
+	Seed            int64
+	Score           int
+	Level           int
+	Time            int // in ticks
+	PiecesDropped   int
+	QuickDrops      int
+	Swaps           int
+	LinesDestroyed  int
+	DoubleKills     int
+	TripleKills     int
+	QuadKills       int
+	TSpins          int // full t-spins, including ones that didn't clear any lines
+	TSpinSingles    int
+	TSpinDoubles    int
+	TSpinTriples    int
+	TSpinMinis      int
+	MaxCombo        int
+	BackToBacks     int
+	GarbageCleared  int // lines with garbage in them that were cleared
+	GarbageSent     int // lines of garbage sent to the opponent, in versus games
+	GarbageReceived int // lines of garbage from the opponent that rose into the well
+}
@@ -0,0 +1,205 @@
+package engine
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/bennicholls/tyumi/vec"
+)
+
+// setupGame starts a game from a fixed position, with the provided rows at the bottom of the well and a queue of
+// pieces to play.
+func setupGame(rows []string, queue ...PieceType) *Game {
+	config := DefaultConfig()
+	config.Setup = &Setup{Board: rows, Queue: queue, Held: NO_PIECE}
+
+	var g Game
+	g.Init(config)
+	return &g
+}
+
+// scriptedActions is an arbitrary but busy mix of inputs to play a game with.
+func scriptedActions(tick int) (actions []Action) {
+	switch tick % 17 {
+	case 2:
+		actions = append(actions, ACTION_MOVE_LEFT)
+	case 5:
+		actions = append(actions, ACTION_RELEASE_LEFT, ACTION_ROTATE_CW)
+	case 8:
+		actions = append(actions, ACTION_MOVE_RIGHT)
+	case 9:
+		actions = append(actions, ACTION_RELEASE_RIGHT, ACTION_ROTATE_180)
+	case 12:
+		actions = append(actions, ACTION_SOFT_DROP_ON)
+	case 14:
+		actions = append(actions, ACTION_SOFT_DROP_OFF, ACTION_ROTATE_CCW)
+	case 16:
+		actions = append(actions, ACTION_HARD_DROP)
+	}
+
+	if tick%150 == 40 {
+		actions = append(actions, ACTION_HOLD)
+	}
+
+	return
+}
+
+func TestStepDeterministic(t *testing.T) {
+	config := DefaultConfig()
+	config.Seed = 42
+
+	var a, b Game
+	a.Init(config)
+	b.Init(config)
+
+	for tick := 0; !a.IsOver() && tick < 10000; tick++ {
+		actions := scriptedActions(tick)
+		a_events := slices.Clone(a.Step(actions))
+		if b_events := b.Step(actions); !reflect.DeepEqual(a_events, b_events) {
+			t.Fatalf("tick %d: games diverged, %+v vs %+v", tick, a_events, b_events)
+		}
+	}
+
+	if !b.IsOver() {
+		t.Error("one game ended and the other didn't")
+	}
+	if a.Info().PiecesDropped < 10 {
+		t.Errorf("only %d pieces dropped, the test isn't testing much", a.Info().PiecesDropped)
+	}
+
+	// playing the replay back gives the same game again
+	var c Game
+	c.Init(config)
+	player := NewReplayPlayer(a.Replay())
+	for tick := 0; !player.Done(tick); tick++ {
+		c.Step(player.Actions(tick))
+	}
+
+	if c.Info() != a.Info() {
+		t.Errorf("replay finished with %+v, want %+v", c.Info(), a.Info())
+	}
+	if !slices.Equal(c.Board().Rows(), a.Board().Rows()) {
+		t.Error("replay finished with a different board")
+	}
+}
+
+func TestSpawn(t *testing.T) {
+	g := setupGame(nil, T, O)
+	events := g.Step(nil)
+
+	if len(events) == 0 || events[0].Type != EV_PIECE_SPAWNED {
+		t.Fatalf("first tick fired %+v, want the piece to spawn", events)
+	}
+
+	piece := g.CurrentPiece()
+	if piece.Type != T || piece.Rotation != ROT_0 {
+		t.Errorf("spawned %+v, want a T in its spawn rotation", piece)
+	}
+
+	// new pieces fall one row as soon as they spawn
+	start := piece.StartLocation(g.Board().Size().W)
+	if want := start.Step(vec.DIR_DOWN); piece.Pos != want {
+		t.Errorf("piece is at %v, want %v", piece.Pos, want)
+	}
+
+	if upcoming := g.UpcomingPieces(1); upcoming[0].Type != O {
+		t.Errorf("next piece is %+v, want an O", upcoming[0])
+	}
+}
+
+func TestTopOut(t *testing.T) {
+	t.Run("stack in the invalid lines", func(t *testing.T) {
+		rows := make([]string, DefaultConfig().WellSize.H-2)
+		rows[0] = "G........."
+		for i := 1; i < len(rows); i++ {
+			rows[i] = "GGGGGGGGG."
+		}
+		rows[len(rows)-1] = ".GGGGGGGGG" // a row with a different hole, so nothing is full
+
+		g := setupGame(rows, I)
+		events := g.Step(nil)
+		if len(events) != 1 || events[0].Type != EV_TOP_OUT {
+			t.Errorf("first tick fired %+v, want a top out", events)
+		}
+		if g.Ending() != ENDING_TOP_OUT {
+			t.Errorf("game ended with %v, want a top out", g.Ending())
+		}
+		if g.CurrentPiece().Type != NO_PIECE {
+			t.Error("a piece spawned after topping out")
+		}
+	})
+
+	t.Run("stacking in the middle", func(t *testing.T) {
+		config := DefaultConfig()
+		config.Seed = 1
+
+		var g Game
+		g.Init(config)
+
+		var topped_out bool
+		for tick := 0; !g.IsOver() && tick < 1000; tick++ {
+			for _, event := range g.Step([]Action{ACTION_HARD_DROP}) {
+				topped_out = topped_out || event.Type == EV_TOP_OUT
+			}
+		}
+
+		if !topped_out || g.Ending() != ENDING_TOP_OUT {
+			t.Errorf("game ended with %v, want a top out", g.Ending())
+		}
+		if lines := g.Info().LinesDestroyed; lines != 0 {
+			t.Errorf("cleared %d lines stacking in the middle", lines)
+		}
+	})
+}
+
+func TestLockPiece(t *testing.T) {
+	// 2 lines with the hole on the right, for a vertical I
+	g := setupGame([]string{"GGGGGGGGG.", "GGGGGGGGG."}, I, O)
+	g.Step(nil)
+	g.Step([]Action{ACTION_ROTATE_CW, ACTION_MOVE_RIGHT})
+	for g.CurrentPiece().Pos.X < 7 {
+		g.Step([]Action{ACTION_RELEASE_RIGHT, ACTION_MOVE_RIGHT})
+	}
+
+	events := g.Step([]Action{ACTION_HARD_DROP})
+	i := slices.IndexFunc(events, func(e Event) bool { return e.Type == EV_PIECE_LOCKED })
+	if i == -1 {
+		t.Fatalf("hard drop fired %+v, want the piece to lock", events)
+	}
+
+	h := g.Board().Size().H
+	if locked := events[i]; !slices.Equal(locked.Lines, []int{h - 2, h - 1}) || !locked.Dropped {
+		t.Errorf("locked %+v, want a hard drop completing the bottom 2 lines", locked)
+	}
+	if info := g.Info(); info.LinesDestroyed != 2 || info.DoubleKills != 1 || info.PiecesDropped != 1 {
+		t.Errorf("info after the double: %+v", info)
+	}
+	if g.CurrentPiece().Type != NO_PIECE {
+		t.Error("piece still in play after locking")
+	}
+
+	// full lines stay on the board until the next piece spawns
+	if lines := g.Board().FullLines(); len(lines) != 2 {
+		t.Errorf("%d full lines after locking, want 2", len(lines))
+	}
+
+	var destroyed bool
+	for tick := 0; g.CurrentPiece().Type == NO_PIECE && tick < 100; tick++ {
+		for _, event := range g.Step(nil) {
+			destroyed = destroyed || event.Type == EV_LINES_DESTROYED
+		}
+	}
+
+	if !destroyed {
+		t.Error("lines weren't destroyed before the next piece spawned")
+	}
+
+	rows := g.Board().Rows()
+	if want := []string{".........I", ".........I"}; !slices.Equal(rows[h-2:], want) {
+		t.Errorf("bottom of the well is %q, want %q", rows[h-2:], want)
+	}
+	if g.CurrentPiece().Type != O {
+		t.Errorf("spawned %+v, want the O", g.CurrentPiece())
+	}
+}
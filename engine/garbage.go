@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"os"
+)
+
+// Garbage describes the garbage rows a game starts with, and how more of it rises up from the bottom of the well.
+type Garbage struct {
+	StartRows int       // rows of garbage in the well at the start of the game
+	Messiness float64   // chance of the hole moving to a different column on the next row. 0 lines them all up
+	Rise      RiseCurve // how often new rows of garbage rise from the bottom. an empty curve means garbage never rises
+	Goal      int       // the game is won once this many garbage lines are cleared. 0 means there is no goal
+}
+
+// RiseCurve describes how often garbage rises as a game goes on. Intervals[i] is the number of ticks between rows of
+// garbage during the i-th TimeStep ticks of the game. Once the curve runs out the last interval is used forever.
+type RiseCurve struct {
+	Name      string
+	Intervals []int
+	TimeStep  int
+}
+
+// Interval returns the number of ticks between rising rows of garbage at the provided time. 0 means no garbage.
+func (rc RiseCurve) Interval(time int) int {
+	if len(rc.Intervals) == 0 {
+		return 0
+	}
+
+	step := 0
+	if rc.TimeStep > 0 {
+		step = min(time/rc.TimeStep, len(rc.Intervals)-1)
+	}
+
+	return rc.Intervals[step]
+}
+
+// FixedRise makes a curve where garbage rises at the same rate forever.
+func FixedRise(interval int) RiseCurve {
+	return RiseCurve{Intervals: []int{interval}}
+}
+
+// LoadRiseCurve reads a rise curve from a json file.
+func LoadRiseCurve(filename string) (curve RiseCurve, err error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &curve)
+	if err != nil {
+		return
+	}
+
+	if len(curve.Intervals) == 0 {
+		err = errors.New("rise curve has no intervals")
+	}
+
+	return
+}
+
+// Preset rise curves, by name. They speed up every 30 seconds.
+var RiseCurves = map[string]RiseCurve{
+	"gentle": {
+		Name:      "gentle",
+		Intervals: []int{600, 540, 480, 420, 360, 300, 240},
+		TimeStep:  30 * 60,
+	},
+	"standard": {
+		Name:      "standard",
+		Intervals: []int{480, 420, 360, 300, 240, 200, 160, 120},
+		TimeStep:  30 * 60,
+	},
+	"brutal": {
+		Name:      "brutal",
+		Intervals: []int{300, 240, 180, 150, 120, 90, 60},
+		TimeStep:  30 * 60,
+	},
+}
+
+// the garbage gets its own random numbers so that the piece sequence is the same regardless of garbage settings
+const garbage_seed_offset int64 = 7919
+
+func (g *Game) initGarbage() {
+	g.garbage_rng = rand.New(rand.NewSource(g.info.Seed + garbage_seed_offset))
+	g.garbage_hole = g.garbage_rng.Intn(g.board.Size().W)
+	g.garbage_timer = 0
+
+	for range g.config.Garbage.StartRows {
+		g.board.InsertGarbage(g.nextGarbageHole(), 0)
+	}
+}
+
+// nextGarbageHole picks the column of the hole for the next row of garbage.
+func (g *Game) nextGarbageHole() int {
+	if w := g.board.Size().W; w > 1 && g.garbage_rng.Float64() < g.config.Garbage.Messiness {
+		// pick one of the other columns
+		g.garbage_hole = (g.garbage_hole + 1 + g.garbage_rng.Intn(w-1)) % w
+	}
+
+	return g.garbage_hole
+}
+
+// updateGarbage raises a new row of garbage whenever the rise interval comes around.
+func (g *Game) updateGarbage() {
+	interval := g.config.Garbage.Rise.Interval(g.info.Time)
+	if interval == 0 {
+		return
+	}
+
+	g.garbage_timer += 1
+	if g.garbage_timer >= interval {
+		g.garbage_timer = 0
+		g.raiseGarbage(1)
+	}
+}
+
+// raiseGarbage pushes rows of garbage up from the bottom of the well. The current piece is pushed up with the stack
+// if it would end up inside it. If anything gets pushed out of the top of the well, the game is over.
+func (g *Game) raiseGarbage(rows int) {
+	for range rows {
+		overflow := g.board.InsertGarbage(g.nextGarbageHole(), g.info.Time)
+
+		if g.current_piece.Type != NO_PIECE && !g.board.IsValidPosition(g.current_piece) {
+			g.current_piece.Pos.Move(0, -1)
+			if !g.board.IsValidPosition(g.current_piece) {
+				overflow = true
+			}
+		}
+
+		if overflow {
+			g.ending = ENDING_TOP_OUT
+			g.fireEvent(Event{Type: EV_GARBAGE_RISEN})
+			g.fireEvent(Event{Type: EV_TOP_OUT})
+			return
+		}
+	}
+
+	if g.current_piece.Type != NO_PIECE {
+		g.updateGhost()
+	}
+
+	g.fireEvent(Event{Type: EV_GARBAGE_RISEN, Piece: g.current_piece})
+}
+
+// GarbageTimer returns the number of ticks since garbage last rose, and how many ticks it takes to rise. The interval
+// is 0 if garbage doesn't rise.
+func (g *Game) GarbageTimer() (ticks, interval int) {
+	return g.garbage_timer, g.config.Garbage.Rise.Interval(g.info.Time)
+}
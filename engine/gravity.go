@@ -0,0 +1,192 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+
+	"github.com/bennicholls/tyumi/util"
+	"github.com/bennicholls/tyumi/vec"
+)
+
+// Gravity is measured in fractions of a row per tick, so pieces can fall slower than one row per tick or many rows per
+// tick. ROW is one row per tick (1G).
+const ROW int = 65536
+
+// 20G, the fastest gravity there is. Pieces fall to the floor instantly.
+const MAX_GRAVITY int = 20 * ROW
+
+// GravityCurve describes how fast pieces fall as the game progresses. Each entry is the number of ticks it takes a
+// piece to fall one row, so values less than 1 make pieces fall multiple rows per tick. Curves can be loaded from json
+// files, see LoadGravityCurve().
+type GravityCurve struct {
+	Name     string
+	Levels   []float64 // ticks per row for each level, starting at level 1. the last entry is used past the end.
+	TimeStep int       // if non-zero, the curve advances one entry every TimeStep ticks instead of once per level
+}
+
+// Gravity returns the gravity in 1/ROWths of a row per tick, for the given level and game time.
+func (gc GravityCurve) Gravity(level, time int) int {
+	if len(gc.Levels) == 0 {
+		return ROW
+	}
+
+	index := level - 1
+	if gc.TimeStep > 0 {
+		index = time / gc.TimeStep
+	}
+
+	ticks_per_row := gc.Levels[min(max(index, 0), len(gc.Levels)-1)]
+	if ticks_per_row <= 0 {
+		return MAX_GRAVITY
+	}
+
+	// pieces always fall eventually, however slow the curve asks for
+	return util.Clamp(int(math.Round(float64(ROW)/ticks_per_row)), 1, MAX_GRAVITY)
+}
+
+// LoadGravityCurve reads a gravity curve from a json file.
+func LoadGravityCurve(filename string) (curve GravityCurve, err error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &curve)
+	if err != nil {
+		return
+	}
+
+	if len(curve.Levels) == 0 {
+		err = errors.New("gravity curve has no levels")
+	}
+
+	return
+}
+
+// Preset gravity curves, by name.
+var GravityCurves = map[string]GravityCurve{
+	"guideline": GuidelineGravity,
+	"nes":       NESGravity,
+	"classic":   ClassicGravity,
+	"master":    MasterGravity,
+}
+
+// Guideline gravity: (0.8 - (level-1)*0.007)^(level-1) seconds per row, reaching 20G at level 20.
+var GuidelineGravity = GravityCurve{
+	Name: "Guideline",
+	Levels: func() (levels []float64) {
+		for level := 1.0; level <= 20; level++ {
+			seconds := math.Pow(0.8-(level-1)*0.007, level-1)
+			levels = append(levels, max(seconds*60, 1.0/20))
+		}
+		return
+	}(),
+}
+
+// Frames per row from the NES version. Our level 1 is level 0 in the NES.
+var NESGravity = GravityCurve{
+	Name:   "NES",
+	Levels: []float64{48, 43, 38, 33, 28, 23, 18, 13, 8, 6, 5, 5, 5, 4, 4, 4, 3, 3, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1},
+}
+
+// Gravity from TGM's master mode, for LEVEL_TGM levelling. It creeps up through the first 200 levels, drops back to a
+// crawl at 200, then races to 20G by level 500.
+var MasterGravity = GravityCurve{
+	Name: "Master",
+	Levels: func() (levels []float64) {
+		// level the gravity starts at, and the gravity in 1/256ths of a row per tick
+		table := []struct{ level, gravity int }{
+			{0, 4}, {30, 6}, {35, 8}, {40, 10}, {50, 12}, {60, 16}, {70, 32}, {80, 48}, {90, 64}, {100, 80},
+			{120, 96}, {140, 112}, {160, 128}, {170, 144}, {200, 4}, {220, 32}, {230, 64}, {233, 96}, {236, 128},
+			{239, 160}, {243, 192}, {247, 224}, {251, 256}, {300, 512}, {330, 768}, {360, 1024}, {400, 1280},
+			{420, 1024}, {450, 768}, {500, 5120},
+		}
+
+		// the curve starts at level 1, which is level 0 or 1 here since they have the same gravity
+		gravity := 4
+		for level := 1; level <= 500; level++ {
+			for _, step := range table {
+				if step.level == level {
+					gravity = step.gravity
+				}
+			}
+			levels = append(levels, 256/float64(gravity))
+		}
+		return
+	}(),
+}
+
+// The original TyTris speed curve, where gravity increases every 5 seconds regardless of level.
+var ClassicGravity = GravityCurve{
+	Name: "Classic",
+	Levels: func() (levels []float64) {
+		for ticks := 45; ticks >= 5; ticks-- {
+			levels = append(levels, float64(ticks))
+		}
+		return
+	}(),
+	TimeStep: 300,
+}
+
+// applyGravity moves the current piece down if it's time for it to fall. Returns whether the piece tried to fall
+// this tick.
+func (g *Game) applyGravity() bool {
+	gravity := g.gravity
+	soft_drop := false
+	if g.speed_up {
+		soft_drop_gravity := MAX_GRAVITY
+		if g.config.Handling.SoftDropGravity > 0 {
+			soft_drop_gravity = ROW / g.config.Handling.SoftDropGravity
+		}
+
+		if soft_drop_gravity > gravity {
+			gravity = soft_drop_gravity
+			soft_drop = true
+		}
+	}
+
+	var rows int
+	if gravity >= MAX_GRAVITY {
+		// straight to the floor, even in wells more than 20 rows tall
+		rows = g.board.Size().H
+		g.gravity_accumulator = 0
+	} else {
+		g.gravity_accumulator += gravity
+		rows = g.gravity_accumulator / ROW
+		g.gravity_accumulator %= ROW
+		if rows == 0 {
+			return false
+		}
+	}
+
+	var cells int
+	for range rows {
+		if !g.testMove(vec.DIR_DOWN) {
+			break
+		}
+		g.movePiece(vec.DIR_DOWN)
+		cells += 1
+	}
+
+	if soft_drop {
+		g.addDropPoints(cells, false)
+	}
+
+	return true
+}
+
+// updateGravity sets the gravity for the current level and time.
+func (g *Game) updateGravity() {
+	old_gravity := g.gravity
+	g.gravity = g.config.Gravity.Gravity(g.info.Level, g.info.Time)
+	if g.gravity != old_gravity {
+		g.fireEvent(Event{Type: EV_GRAVITY_CHANGED})
+	}
+}
+
+// Gravity returns the number of ticks it currently takes for a piece to fall one row.
+func (g *Game) Gravity() float64 {
+	return float64(ROW) / float64(g.gravity)
+}
@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"github.com/bennicholls/tyumi/vec"
+)
+
+// Handling controls how the game responds to held inputs. All values are in ticks.
+type Handling struct {
+	DAS             int  // Delayed Auto Shift: how long left/right must be held before the piece starts moving on its own
+	ARR             int  // Auto Repeat Rate: time between auto-shifted moves. 0 moves the piece straight to the wall
+	DASCut          int  // time after a hard drop before auto-shift can move the next piece
+	CarryDAS        bool // whether a charged auto-shift carries over to the next piece, or has to charge again
+	SoftDropGravity int  // gravity while soft dropping. 0 drops the piece to the floor instantly
+}
+
+func DefaultHandling() Handling {
+	return Handling{
+		DAS:             10,
+		ARR:             2,
+		DASCut:          0,
+		CarryDAS:        true,
+		SoftDropGravity: 8,
+	}
+}
+
+// startShift is called when a direction is pressed. the piece moves once immediately and then starts charging its
+// auto-shift.
+func (g *Game) startShift(dir int) {
+	g.shift_dir = dir
+	g.das_timer = 0
+	g.arr_timer = g.config.Handling.ARR - 1
+	g.movePiece(vec.Direction{dir, 0})
+}
+
+// stopShift is called when a direction is released. If the other direction is still held, it takes over and starts
+// charging.
+func (g *Game) stopShift(dir int) {
+	if g.shift_dir != dir {
+		return
+	}
+
+	g.shift_dir = 0
+	if (dir == -1 && g.right_held) || (dir == 1 && g.left_held) {
+		g.shift_dir = -dir
+		g.das_timer = 0
+		g.arr_timer = g.config.Handling.ARR - 1
+	}
+}
+
+// updateAutoShift charges the auto-shift for the held direction, moving the current piece once it is charged.
+func (g *Game) updateAutoShift() {
+	if g.das_cut_timer > 0 {
+		g.das_cut_timer -= 1
+	}
+
+	if g.shift_dir == 0 {
+		return
+	}
+
+	g.das_timer += 1
+	if g.das_timer < g.config.Handling.DAS || g.das_cut_timer > 0 || g.current_piece.Type == NO_PIECE {
+		return
+	}
+
+	dir := vec.Direction{g.shift_dir, 0}
+	if g.config.Handling.ARR == 0 {
+		for g.testMove(dir) {
+			g.movePiece(dir)
+		}
+		return
+	}
+
+	g.arr_timer += 1
+	if g.arr_timer >= g.config.Handling.ARR {
+		g.movePiece(dir)
+		g.arr_timer = 0
+	}
+}
@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"github.com/bennicholls/tyumi/vec"
+)
+
+// LockResetMode decides how moving a piece that has landed affects its lock delay.
+type LockResetMode int
+
+const (
+	LOCK_RESET_MOVE     LockResetMode = iota // moves and rotations restart the lock delay, up to Config.MaxLockResets times
+	LOCK_RESET_INFINITE                      // moves and rotations always restart the lock delay
+	LOCK_RESET_STEP                          // lock delay only restarts when the piece falls to a new lowest row
+)
+
+// Lock reset modes, by name.
+var LockResetModes = map[string]LockResetMode{
+	"move":     LOCK_RESET_MOVE,
+	"infinite": LOCK_RESET_INFINITE,
+	"step":     LOCK_RESET_STEP,
+}
+
+// updateLockDelay runs the lock timer while the current piece is resting on something, locking the piece when the
+// timer runs out. If lock delay is disabled, pieces lock on the first gravity tick where they can't fall any further.
+func (g *Game) updateLockDelay(gravity_tick bool) {
+	if g.testMove(vec.DIR_DOWN) {
+		return
+	}
+
+	if g.lockDelay() == 0 {
+		if gravity_tick {
+			g.lockPiece(false)
+		}
+		return
+	}
+
+	g.lock_timer += 1
+	if g.lock_timer >= g.lockDelay() {
+		g.lockPiece(false)
+	}
+}
+
+// resetLockTimer is called whenever the current piece moves or rotates successfully, restarting the lock delay if the
+// lock reset mode allows it. Falling to a new lowest row always restarts the lock delay and the count of resets.
+func (g *Game) resetLockTimer() {
+	if g.current_piece.Pos.Y > g.lowest_row {
+		g.lowest_row = g.current_piece.Pos.Y
+		g.lock_timer = 0
+		g.lock_resets = 0
+		return
+	}
+
+	if g.lock_timer == 0 {
+		return
+	}
+
+	switch g.config.LockReset {
+	case LOCK_RESET_MOVE:
+		if g.lock_resets < g.config.MaxLockResets {
+			g.lock_timer = 0
+			g.lock_resets += 1
+		}
+	case LOCK_RESET_INFINITE:
+		g.lock_timer = 0
+	}
+}
+
+// LockTimer returns how many ticks the current piece has spent waiting to lock, and the lock delay it is waiting for.
+func (g *Game) LockTimer() (ticks, delay int) {
+	return g.lock_timer, g.lockDelay()
+}
@@ -0,0 +1,259 @@
+package engine
+
+import (
+	"iter"
+
+	"github.com/bennicholls/tyumi/gfx/col"
+	"github.com/bennicholls/tyumi/util"
+	"github.com/bennicholls/tyumi/vec"
+)
+
+// rotation directions
+const (
+	CW         = 1
+	CCW        = -1
+	ROTATE_180 = 2
+)
+
+type PieceType int
+
+const (
+	I PieceType = iota
+	J
+	L
+	O
+	S
+	Z
+	T
+
+	MAX_PIECETYPE // number of standard pieces
+	NO_PIECE
+	GARBAGE // blocks that rise up from the bottom of the well. never part of a piece
+
+	FIRST_CUSTOM_PIECE // pieces loaded from piece set files are numbered from here on, within their set. see LoadPieceSet()
+)
+
+// garbage blocks are grey, to stand out from all the colourful pieces
+var garbage_colour uint32 = col.MakeOpaque(90, 90, 90)
+var garbage_highlight_colour uint32 = col.MakeOpaque(140, 140, 140)
+
+type PieceData struct {
+	default_shape    []bool
+	stride           int
+	colour           uint32
+	highlight_colour uint32
+	start_row        int
+	start_shift      int                    // columns to the right of center the piece spawns
+	fixed            bool                   // pieces that look the same in every rotation don't rotate at all
+	kicks            map[[2]int][]vec.Coord // kicks for the piece's rotations. nil uses the game's rotation system
+}
+
+var pieceData [MAX_PIECETYPE]PieceData = [MAX_PIECETYPE]PieceData{
+	{ // I
+		default_shape: []bool{
+			false, false, false, false,
+			true, true, true, true,
+			false, false, false, false,
+			false, false, false, false,
+		},
+		stride:           4,
+		colour:           col.MakeOpaque(0, 163, 217),
+		highlight_colour: col.MakeOpaque(102, 217, 255),
+		start_row:        0,
+	},
+
+	{ // J
+		default_shape: []bool{
+			true, false, false,
+			true, true, true,
+			false, false, false,
+		},
+		stride:           3,
+		colour:           col.MakeOpaque(26, 0, 102),
+		highlight_colour: col.MakeOpaque(64, 0, 255),
+		start_row:        0,
+	},
+
+	{ // L
+		default_shape: []bool{
+			false, false, true,
+			true, true, true,
+			false, false, false,
+		},
+		stride:           3,
+		colour:           col.MakeOpaque(255, 128, 0),
+		highlight_colour: col.MakeOpaque(255, 178, 102),
+		start_row:        0,
+	},
+
+	{ // O
+		default_shape: []bool{
+			true, true,
+			true, true,
+		},
+		stride:           2,
+		colour:           col.MakeOpaque(217, 217, 0),
+		highlight_colour: col.MakeOpaque(255, 255, 102),
+		start_row:        0,
+		fixed:            true,
+	},
+
+	{ // S
+		default_shape: []bool{
+			false, true, true,
+			true, true, false,
+			false, false, false,
+		},
+		stride:           3,
+		colour:           col.MakeOpaque(0, 102, 0),
+		highlight_colour: col.MakeOpaque(0, 217, 0),
+		start_row:        0,
+	},
+
+	{ // Z
+		default_shape: []bool{
+			true, true, false,
+			false, true, true,
+			false, false, false,
+		},
+		stride:           3,
+		colour:           col.MakeOpaque(178, 0, 45),
+		highlight_colour: col.MakeOpaque(255, 51, 102),
+		start_row:        0,
+	},
+
+	{ // T
+		default_shape: []bool{
+			false, true, false,
+			true, true, true,
+			false, false, false,
+		},
+		stride:           3,
+		colour:           col.MakeOpaque(140, 0, 140),
+		highlight_colour: col.MakeOpaque(255, 51, 255),
+		start_row:        0,
+	},
+}
+
+type Piece struct {
+	Type     PieceType
+	Rotation int
+	Pos      vec.Coord
+
+	custom *PieceData // definition of a piece from a piece set file. nil for the standard pieces
+}
+
+// data returns the definition of the piece, whether it's one of the standard pieces or a custom one.
+func (p Piece) data() *PieceData {
+	if p.custom != nil {
+		return p.custom
+	}
+
+	return &pieceData[p.Type]
+}
+
+// Letter returns the letter the piece is known by, or "" for custom pieces.
+func (pt PieceType) Letter() string {
+	for letter, piece := range pieceLetters {
+		if piece == pt {
+			return string(letter)
+		}
+	}
+
+	return ""
+}
+
+func (p Piece) Colour() uint32 {
+	return p.data().colour
+}
+
+func (p Piece) Highlight() uint32 {
+	return p.data().highlight_colour
+}
+
+// StartLocation returns where the piece spawns in a well of the given width. Pieces spawn centered, rounding to the
+// left.
+func (p Piece) StartLocation(well_width int) vec.Coord {
+	data := p.data()
+	return vec.Coord{(well_width-data.stride)/2 + data.start_shift, data.start_row}
+}
+
+func (p Piece) Stride() int {
+	return p.data().stride
+}
+
+// GetShape returns the blocks of the piece in its current rotation, as a stride x stride grid.
+func (p Piece) GetShape() []bool {
+	if p.Rotation == 0 {
+		return p.data().default_shape
+	}
+
+	return rotateShape(p.data().default_shape, p.Stride(), p.Rotation)
+}
+
+// rotateShape rotates a stride x stride grid of blocks clockwise the provided number of times.
+func rotateShape(def_shape []bool, stride, rotation int) []bool {
+	rot_shape := make([]bool, len(def_shape))
+
+	for i, block := range def_shape {
+		if block {
+			pos := vec.IndexToCoord(i, stride)
+			var rot_pos vec.Coord
+			switch rotation {
+			case 1:
+				rot_pos = vec.Coord{-pos.Y + stride - 1, pos.X}
+			case 2:
+				rot_pos = vec.Coord{-pos.X + stride - 1, -pos.Y + stride - 1}
+			case 3:
+				rot_pos = vec.Coord{pos.Y, -pos.X + stride - 1}
+			}
+			rot_shape[rot_pos.ToIndex(stride)] = true
+		}
+	}
+
+	return rot_shape
+}
+
+// EachBlock iterates over the positions of each block in the piece, in well coordinates.
+func (p Piece) EachBlock() iter.Seq[vec.Coord] {
+	return func(yield func(vec.Coord) bool) {
+		stride := p.Stride()
+		for i, block := range p.GetShape() {
+			if block && !yield(p.Pos.Add(vec.IndexToCoord(i, stride))) {
+				return
+			}
+		}
+	}
+}
+
+func (p Piece) Dims() vec.Dims {
+	return vec.Dims{p.Stride(), p.Stride()}
+}
+
+// Bounds returns the smallest area around the piece's blocks in its current rotation, relative to the top-left of
+// its bounding box. Useful for showing pieces without the empty rows and columns around them.
+func (p Piece) Bounds() (bounds vec.Rect) {
+	stride := p.Stride()
+	min_pos, max_pos := vec.Coord{stride, stride}, vec.Coord{-1, -1}
+	for i, block := range p.GetShape() {
+		if block {
+			pos := vec.IndexToCoord(i, stride)
+			min_pos = vec.Coord{min(min_pos.X, pos.X), min(min_pos.Y, pos.Y)}
+			max_pos = vec.Coord{max(max_pos.X, pos.X), max(max_pos.Y, pos.Y)}
+		}
+	}
+
+	if max_pos.X < 0 {
+		return
+	}
+
+	return vec.Rect{min_pos, vec.Dims{max_pos.X - min_pos.X + 1, max_pos.Y - min_pos.Y + 1}}
+}
+
+func (p *Piece) Rotate(dir int) {
+	if p.data().fixed {
+		return
+	}
+
+	p.Rotation = util.CycleClamp(p.Rotation+dir, 0, 3)
+}
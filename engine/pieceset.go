@@ -0,0 +1,250 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bennicholls/tyumi/gfx/col"
+	"github.com/bennicholls/tyumi/vec"
+)
+
+// PieceSet is the collection of pieces a game is played with. The randomizers deal out pieces from the set.
+type PieceSet struct {
+	Name   string
+	Pieces []PieceType
+
+	custom []PieceData // definitions of the set's custom pieces, indexed from FIRST_CUSTOM_PIECE
+}
+
+// data returns the definition of a piece in the set, whether it's one of the standard pieces or a custom one.
+func (ps PieceSet) data(pt PieceType) *PieceData {
+	if pt >= FIRST_CUSTOM_PIECE {
+		return &ps.custom[pt-FIRST_CUSTOM_PIECE]
+	}
+
+	return &pieceData[pt]
+}
+
+// newPiece makes a piece of the provided type, ready to be spawned.
+func (ps PieceSet) newPiece(pt PieceType) Piece {
+	piece := Piece{Type: pt}
+	if pt >= FIRST_CUSTOM_PIECE {
+		piece.custom = ps.data(pt)
+	}
+
+	return piece
+}
+
+// Colour returns the colour of blocks of the provided type, which can be any piece in the set or garbage.
+func (ps PieceSet) Colour(pt PieceType) uint32 {
+	if pt == GARBAGE {
+		return garbage_colour
+	}
+
+	return ps.data(pt).colour
+}
+
+// Highlight returns the colour of the top edge of blocks of the provided type, which can be any piece in the set or
+// garbage.
+func (ps PieceSet) Highlight(pt PieceType) uint32 {
+	if pt == GARBAGE {
+		return garbage_highlight_colour
+	}
+
+	return ps.data(pt).highlight_colour
+}
+
+// StandardPieces is the set of seven tetrominoes.
+var StandardPieces = PieceSet{Name: "standard", Pieces: []PieceType{I, J, L, O, S, Z, T}}
+
+// Preset piece sets, by name.
+var PieceSets = map[string]PieceSet{
+	"standard": StandardPieces,
+}
+
+// pieceSetFile is the format piece sets are stored in on disk.
+type pieceSetFile struct {
+	Name   string
+	Pieces []pieceFile
+}
+
+// pieceFile describes a single piece in a piece set file. A piece with no shape is one of the standard tetrominoes,
+// named by its letter, so sets can mix custom pieces with the usual ones.
+type pieceFile struct {
+	Name       string
+	Shape      []string            // rows of the piece in its spawn rotation, top row first. '#' is a block, anything else is empty
+	Colour     string              // hex colour, like "00a3d9"
+	Highlight  string              // hex colour for the top edge of the blocks
+	StartRow   int                 // row of the well the top of the piece's bounding box spawns in
+	StartShift int                 // columns to the right of center the piece spawns
+	Kicks      string              // a preset kick table: "srs", "srs-i" or "none". leave empty to use the game's rotation system
+	KickTable  map[string][][2]int // a custom kick table, keyed by rotation like "0>R" or "L>2". y increases downward
+}
+
+// kick tables that pieces can use by name
+var kick_presets = map[string]map[[2]int][]vec.Coord{
+	"srs":   srs_kicks_JLSTZ,
+	"srs-i": srs_kicks_I,
+	"none":  {},
+}
+
+// LoadPieceSet reads a piece set from a json file. Its custom pieces only belong to the set, so every set loaded is
+// independent of the others.
+func LoadPieceSet(filename string) (set PieceSet, err error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return
+	}
+
+	var file pieceSetFile
+	err = json.Unmarshal(data, &file)
+	if err != nil {
+		return
+	}
+
+	if len(file.Pieces) == 0 {
+		return set, errors.New("piece set has no pieces")
+	}
+
+	// check all the pieces before adding any of them, so a broken file doesn't leave half a set behind
+	definitions := make([]PieceData, len(file.Pieces))
+	for i, piece := range file.Pieces {
+		if len(piece.Shape) == 0 {
+			continue
+		}
+
+		definitions[i], err = piece.definition()
+		if err != nil {
+			return set, fmt.Errorf("piece %d (%s): %w", i+1, piece.Name, err)
+		}
+	}
+
+	set.Name = file.Name
+	for i, piece := range file.Pieces {
+		if len(piece.Shape) == 0 {
+			standard, err := ParseSequence(piece.Name)
+			if err != nil || len(standard) != 1 {
+				return PieceSet{}, fmt.Errorf("piece %d has no shape and isn't a standard piece: %q", i+1, piece.Name)
+			}
+			set.Pieces = append(set.Pieces, standard[0])
+			continue
+		}
+
+		set.custom = append(set.custom, definitions[i])
+		set.Pieces = append(set.Pieces, FIRST_CUSTOM_PIECE+PieceType(len(set.custom)-1))
+	}
+
+	return
+}
+
+// definition converts the piece from the file into the engine's representation, checking that it makes sense.
+func (pf pieceFile) definition() (data PieceData, err error) {
+	// shapes are padded out to a square, so they can be rotated within their bounding box
+	data.stride = len(pf.Shape)
+	for _, row := range pf.Shape {
+		data.stride = max(data.stride, len(row))
+	}
+
+	data.default_shape = make([]bool, data.stride*data.stride)
+	blocks := 0
+	for y, row := range pf.Shape {
+		for x, cell := range row {
+			if cell == '#' {
+				data.default_shape[vec.Coord{x, y}.ToIndex(data.stride)] = true
+				blocks += 1
+			}
+		}
+	}
+
+	if blocks == 0 {
+		return data, errors.New("shape has no blocks")
+	}
+
+	data.fixed = slices.Equal(data.default_shape, rotateShape(data.default_shape, data.stride, 1))
+
+	data.colour, err = parseHexColour(pf.Colour)
+	if err != nil {
+		return data, fmt.Errorf("bad colour: %w", err)
+	}
+
+	data.highlight_colour = data.colour
+	if pf.Highlight != "" {
+		data.highlight_colour, err = parseHexColour(pf.Highlight)
+		if err != nil {
+			return data, fmt.Errorf("bad highlight: %w", err)
+		}
+	}
+
+	data.start_row = pf.StartRow
+	data.start_shift = pf.StartShift
+
+	if pf.KickTable != nil {
+		data.kicks, err = parseKickTable(pf.KickTable)
+		if err != nil {
+			return
+		}
+	} else if pf.Kicks != "" {
+		kicks, ok := kick_presets[pf.Kicks]
+		if !ok {
+			return data, errors.New("no kick preset called " + pf.Kicks)
+		}
+		data.kicks = kicks
+	}
+
+	return
+}
+
+// parseHexColour reads a colour written like "00a3d9". A leading '#' is allowed.
+func parseHexColour(text string) (colour uint32, err error) {
+	text = strings.TrimPrefix(text, "#")
+	if len(text) != 6 {
+		return 0, errors.New("colours must be 6 hex digits, got " + strconv.Quote(text))
+	}
+
+	rgb, err := strconv.ParseUint(text, 16, 32)
+	if err != nil {
+		return
+	}
+
+	return col.MakeOpaque(int(rgb>>16&0xff), int(rgb>>8&0xff), int(rgb&0xff)), nil
+}
+
+// parseKickTable reads a kick table from a piece set file. Rotations are written as "from>to" using the rotation state
+// names 0, R, 2 and L.
+func parseKickTable(table map[string][][2]int) (kicks map[[2]int][]vec.Coord, err error) {
+	states := map[string]int{"0": ROT_0, "R": ROT_R, "2": ROT_2, "L": ROT_L}
+
+	kicks = make(map[[2]int][]vec.Coord)
+	for rotation, offsets := range table {
+		from_name, to_name, _ := strings.Cut(strings.ToUpper(rotation), ">")
+		from, from_ok := states[from_name]
+		to, to_ok := states[to_name]
+		if !from_ok || !to_ok || from == to {
+			return nil, errors.New("bad rotation in kick table: " + rotation)
+		}
+
+		for _, offset := range offsets {
+			kicks[[2]int{from, to}] = append(kicks[[2]int{from, to}], vec.Coord{offset[0], offset[1]})
+		}
+	}
+
+	return
+}
+
+// Kicks returns the kicks to try when rotating a piece. Pieces from piece sets can bring their own kick table, which
+// only allows the rotations it lists kicks for to kick. Pieces without one use the game's rotation system.
+func (g *Game) Kicks(piece PieceType, from, to int) []vec.Coord {
+	if table := g.config.Pieces.data(piece).kicks; table != nil {
+		if kicks, ok := table[[2]int{from, to}]; ok {
+			return kicks
+		}
+		return []vec.Coord{{0, 0}}
+	}
+
+	return g.config.RotationSystem.Kicks(piece, from, to)
+}
@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+)
+
+// Setup is a fixed starting position for a game, like the ones puzzles start from.
+type Setup struct {
+	Board []string    // rows of blocks at the bottom of the well, top row first. see ParseBoardRow()
+	Queue []PieceType // the pieces the player gets, in order. the game ends once they have all been played
+	Held  PieceType   // the piece starting in the hold. NO_PIECE for none
+}
+
+// Objective is a list of things to achieve in a game. Everything asked for has to be done for the objective to be
+// complete.
+type Objective struct {
+	Lines        int  // lines to clear
+	TSpinDoubles int  // t-spin doubles to perform
+	PerfectClear bool // leave the well completely empty after clearing some lines
+	MaxPieces    int  // the rest has to be done with this many pieces or fewer. 0 means there's no limit
+}
+
+// IsEmpty reports whether the objective doesn't ask for anything, so there is nothing to complete.
+func (o Objective) IsEmpty() bool {
+	return o.Lines == 0 && o.TSpinDoubles == 0 && !o.PerfectClear
+}
+
+// isComplete checks whether everything asked for by the objective has been done.
+func (o Objective) isComplete(info Info, board Board) bool {
+	if o.IsEmpty() {
+		return false
+	}
+
+	if info.LinesDestroyed < o.Lines || info.TSpinDoubles < o.TSpinDoubles {
+		return false
+	}
+
+	if o.PerfectClear && (info.LinesDestroyed == 0 || !board.isEmptyAfterClear()) {
+		return false
+	}
+
+	return true
+}
+
+// ParseBoardRow converts a row of a setup's board into blocks. '.' and ' ' are empty cells, piece letters are blocks
+// of that piece and anything else is garbage.
+func ParseBoardRow(row string) (blocks []PieceType, err error) {
+	for _, letter := range strings.ToUpper(row) {
+		switch letter {
+		case '.', ' ':
+			blocks = append(blocks, NO_PIECE)
+		default:
+			if piece, ok := pieceLetters[letter]; ok {
+				blocks = append(blocks, piece)
+			} else {
+				blocks = append(blocks, GARBAGE)
+			}
+		}
+	}
+
+	if len(blocks) == 0 {
+		err = errors.New("board row is empty")
+	}
+
+	return
+}
+
+// initSetup fills the well and the hold from the config's setup, if it has one.
+func (g *Game) initSetup() {
+	if g.config.Setup == nil {
+		return
+	}
+
+	g.board.Load(g.config.Setup.Board)
+	g.held_piece = g.config.Pieces.newPiece(g.config.Setup.Held)
+}
+
+// isOutOfPieces reports whether the game has run out of pieces to play, either because its queue is empty or because
+// the objective's piece limit has been used up.
+func (g *Game) isOutOfPieces() bool {
+	if g.config.Objective.MaxPieces > 0 && g.info.PiecesDropped >= g.config.Objective.MaxPieces {
+		return true
+	}
+
+	return g.upcoming_pieces[0].Type == NO_PIECE && g.held_piece.Type == NO_PIECE
+}
+
+// PiecesLeft returns the number of pieces left to play, including the current one. Returns 0 if the game doesn't have
+// a fixed number of pieces.
+func (g *Game) PiecesLeft() (left int) {
+	if g.config.Setup != nil {
+		left = len(g.config.Setup.Queue) - g.info.PiecesDropped
+		if g.config.Setup.Held != NO_PIECE {
+			left += 1
+		}
+	}
+
+	if limit := g.config.Objective.MaxPieces; limit > 0 {
+		if left == 0 || limit-g.info.PiecesDropped < left {
+			left = limit - g.info.PiecesDropped
+		}
+	}
+
+	return max(left, 0)
+}
+
+// queueRandomizer deals out a fixed queue of pieces once, and then nothing but NO_PIECE.
+type queueRandomizer struct {
+	queue []PieceType
+}
+
+func QueueRandomizer(queue []PieceType) RandomizerMaker {
+	return func(rng *rand.Rand, pieces []PieceType) Randomizer {
+		return &queueRandomizer{queue: queue}
+	}
+}
+
+func (qr *queueRandomizer) Next() (piece PieceType) {
+	if len(qr.queue) == 0 {
+		return NO_PIECE
+	}
+
+	piece = qr.queue[0]
+	qr.queue = qr.queue[1:]
+	return
+}
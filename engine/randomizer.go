@@ -0,0 +1,186 @@
+package engine
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"slices"
+	"strings"
+)
+
+// A Randomizer decides which pieces the player gets, and in which order.
+type Randomizer interface {
+	Next() PieceType
+}
+
+// RandomizerMaker creates a randomizer for a new game, which must draw all of its randomness from rng and deal out
+// pieces from the game's piece set. Games with the same seed, randomizer and piece set produce the same pieces.
+type RandomizerMaker func(rng *rand.Rand, pieces []PieceType) Randomizer
+
+// Preset randomizers, by name.
+var Randomizers = map[string]RandomizerMaker{
+	"7bag":   BagRandomizer(1),
+	"14bag":  BagRandomizer(2),
+	"random": PureRandomizer,
+	"nes":    NESRandomizer,
+	"tgm":    HistoryRandomizer(4),
+	"tgm2":   HistoryRandomizer(6),
+}
+
+// bagRandomizer deals out pieces from a shuffled bag containing some number of copies of each piece. When the bag is
+// empty it is refilled.
+type bagRandomizer struct {
+	rng    *rand.Rand
+	pieces []PieceType
+	copies int
+	bag    []PieceType
+}
+
+// BagRandomizer returns a maker for bag randomizers with the given number of copies of each piece per bag. 1 copy is
+// the classic 7-bag, 2 copies is a 14-bag.
+func BagRandomizer(copies int) RandomizerMaker {
+	return func(rng *rand.Rand, pieces []PieceType) Randomizer {
+		return &bagRandomizer{rng: rng, pieces: pieces, copies: max(copies, 1)}
+	}
+}
+
+func (br *bagRandomizer) Next() (piece PieceType) {
+	if len(br.bag) == 0 {
+		for range br.copies {
+			br.bag = append(br.bag, br.pieces...)
+		}
+
+		br.rng.Shuffle(len(br.bag), func(i, j int) {
+			br.bag[i], br.bag[j] = br.bag[j], br.bag[i]
+		})
+	}
+
+	piece = br.bag[0]
+	br.bag = br.bag[1:]
+	return
+}
+
+// pureRandomizer picks every piece completely at random. Droughts ahoy!
+type pureRandomizer struct {
+	rng    *rand.Rand
+	pieces []PieceType
+}
+
+func PureRandomizer(rng *rand.Rand, pieces []PieceType) Randomizer {
+	return &pureRandomizer{rng: rng, pieces: pieces}
+}
+
+func (pr *pureRandomizer) Next() PieceType {
+	return pr.pieces[pr.rng.Intn(len(pr.pieces))]
+}
+
+// nesRandomizer works like the NES version: roll for a piece, and if it is the same as the last one (or the roll
+// lands on the unused extra value) roll once more and take whatever comes up.
+type nesRandomizer struct {
+	rng    *rand.Rand
+	pieces []PieceType
+	last   PieceType
+}
+
+func NESRandomizer(rng *rand.Rand, pieces []PieceType) Randomizer {
+	return &nesRandomizer{rng: rng, pieces: pieces, last: NO_PIECE}
+}
+
+func (nr *nesRandomizer) Next() PieceType {
+	roll := nr.rng.Intn(len(nr.pieces) + 1)
+	if roll == len(nr.pieces) || nr.pieces[roll] == nr.last {
+		roll = nr.rng.Intn(len(nr.pieces))
+	}
+
+	nr.last = nr.pieces[roll]
+	return nr.last
+}
+
+// historyRandomizer is the TGM randomizer. It remembers the last 4 pieces dealt, and rerolls up to some number of
+// times to try and find a piece that isn't one of them. The first piece is never an S, Z or O.
+type historyRandomizer struct {
+	rng     *rand.Rand
+	pieces  []PieceType
+	rolls   int
+	history [4]PieceType
+	first   bool
+}
+
+// HistoryRandomizer returns a maker for TGM-style history randomizers. TGM uses 4 rolls, TGM2 uses 6.
+func HistoryRandomizer(rolls int) RandomizerMaker {
+	return func(rng *rand.Rand, pieces []PieceType) Randomizer {
+		return &historyRandomizer{rng: rng, pieces: pieces, rolls: max(rolls, 1), history: [4]PieceType{Z, S, S, Z}, first: true}
+	}
+}
+
+func (hr *historyRandomizer) Next() (piece PieceType) {
+	if hr.first {
+		hr.first = false
+		firsts := slices.DeleteFunc(slices.Clone(hr.pieces), func(p PieceType) bool {
+			return p == S || p == Z || p == O
+		})
+		if len(firsts) == 0 { // a set made of nothing but S, Z and O pieces? fine, have one anyways
+			firsts = hr.pieces
+		}
+		piece = firsts[hr.rng.Intn(len(firsts))]
+	} else {
+		for range hr.rolls {
+			piece = hr.pieces[hr.rng.Intn(len(hr.pieces))]
+			if piece != hr.history[0] && piece != hr.history[1] && piece != hr.history[2] && piece != hr.history[3] {
+				break
+			}
+		}
+	}
+
+	copy(hr.history[1:], hr.history[:3])
+	hr.history[0] = piece
+	return
+}
+
+// sequenceRandomizer isn't random at all, it deals out a fixed sequence of pieces, looping when it reaches the end.
+type sequenceRandomizer struct {
+	sequence []PieceType
+	index    int
+}
+
+func SequenceRandomizer(sequence []PieceType) RandomizerMaker {
+	return func(rng *rand.Rand, pieces []PieceType) Randomizer {
+		return &sequenceRandomizer{sequence: sequence}
+	}
+}
+
+func (sr *sequenceRandomizer) Next() (piece PieceType) {
+	piece = sr.sequence[sr.index]
+	sr.index = (sr.index + 1) % len(sr.sequence)
+	return
+}
+
+// LoadSequence reads a sequence of pieces from a text file, written as piece letters (e.g. "IJLOSZT"). Whitespace is
+// ignored.
+func LoadSequence(filename string) (sequence []PieceType, err error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return
+	}
+
+	return ParseSequence(string(data))
+}
+
+// ParseSequence converts a string of piece letters into a sequence of pieces. Whitespace is ignored.
+func ParseSequence(text string) (sequence []PieceType, err error) {
+	for _, letter := range strings.Join(strings.Fields(strings.ToUpper(text)), "") {
+		piece, ok := pieceLetters[letter]
+		if !ok {
+			return nil, errors.New("invalid piece in sequence: " + string(letter))
+		}
+		sequence = append(sequence, piece)
+	}
+
+	if len(sequence) == 0 {
+		err = errors.New("sequence is empty")
+	}
+
+	return
+}
+
+var pieceLetters = map[rune]PieceType{'I': I, 'J': J, 'L': L, 'O': O, 'S': S, 'Z': Z, 'T': T}
@@ -0,0 +1,204 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// REPLAY_VERSION is the version of the replay file format. Bump it whenever the format or the rules change in a way
+// that would make old replays play back differently.
+const REPLAY_VERSION = 1
+
+var replay_magic = []byte("TYRP")
+
+// most bytes of meta a replay can have. a game's settings are only a few hundred bytes, so anything bigger than this is
+// a corrupt file that would otherwise have us allocate whatever it asks for
+const max_replay_meta = 1 << 20
+
+// A Replay is a recording of a game: its seed and every action given to it, stamped with the tick it was applied on.
+// Games are deterministic, so playing the actions back into a game with the same config reproduces it exactly.
+type Replay struct {
+	Version int
+	Seed    int64
+	Length  int    // number of ticks the game lasted
+	Meta    []byte // whatever the frontend needs to rebuild the game's config (settings, mode, etc.), stored verbatim
+	Inputs  []ReplayInput
+}
+
+type ReplayInput struct {
+	Tick   int
+	Action Action
+}
+
+// record adds the actions applied on the given tick to the replay.
+func (r *Replay) record(tick int, actions []Action) {
+	for _, action := range actions {
+		r.Inputs = append(r.Inputs, ReplayInput{Tick: tick, Action: action})
+	}
+}
+
+// Replay returns the recording of the game so far.
+func (g *Game) Replay() Replay {
+	replay := g.replay
+	replay.Version = REPLAY_VERSION
+	replay.Seed = g.info.Seed
+	replay.Length = g.info.Time
+	return replay
+}
+
+// Write encodes the replay in a compact binary format. Inputs are stored as the number of ticks since the previous
+// input followed by the action, so a whole game is usually only a few kilobytes.
+func (r Replay) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, binary.MaxVarintLen64)
+
+	putUvarint := func(n uint64) {
+		bw.Write(buf[:binary.PutUvarint(buf, n)])
+	}
+
+	bw.Write(replay_magic)
+	putUvarint(REPLAY_VERSION)
+	bw.Write(buf[:binary.PutVarint(buf, r.Seed)])
+	putUvarint(uint64(r.Length))
+	putUvarint(uint64(len(r.Meta)))
+	bw.Write(r.Meta)
+
+	putUvarint(uint64(len(r.Inputs)))
+	last_tick := 0
+	for _, input := range r.Inputs {
+		putUvarint(uint64(input.Tick - last_tick))
+		bw.WriteByte(byte(input.Action))
+		last_tick = input.Tick
+	}
+
+	return bw.Flush()
+}
+
+// ReadReplay decodes a replay written by Replay.Write().
+func ReadReplay(rd io.Reader) (replay Replay, err error) {
+	br := bufio.NewReader(rd)
+
+	magic := make([]byte, len(replay_magic))
+	if _, err = io.ReadFull(br, magic); err != nil {
+		return
+	}
+	if string(magic) != string(replay_magic) {
+		err = errors.New("not a replay file")
+		return
+	}
+
+	version, err := binary.ReadUvarint(br)
+	if err != nil {
+		return
+	}
+	if version != REPLAY_VERSION {
+		// the rules are different in other versions, so they wouldn't play back the same
+		err = fmt.Errorf("replay is version %d, only version %d replays can be played", version, REPLAY_VERSION)
+		return
+	}
+	replay.Version = int(version)
+
+	if replay.Seed, err = binary.ReadVarint(br); err != nil {
+		return
+	}
+
+	length, err := binary.ReadUvarint(br)
+	if err != nil {
+		return
+	}
+	replay.Length = int(length)
+
+	meta_length, err := binary.ReadUvarint(br)
+	if err != nil {
+		return
+	}
+	if meta_length > max_replay_meta {
+		err = fmt.Errorf("replay has %d bytes of meta, more than the most allowed (%d)", meta_length, max_replay_meta)
+		return
+	}
+	replay.Meta = make([]byte, meta_length)
+	if _, err = io.ReadFull(br, replay.Meta); err != nil {
+		return
+	}
+
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return
+	}
+
+	tick := 0
+	for range count {
+		var delta uint64
+		if delta, err = binary.ReadUvarint(br); err != nil {
+			return
+		}
+
+		var action byte
+		if action, err = br.ReadByte(); err != nil {
+			return
+		}
+
+		tick += int(delta)
+		replay.Inputs = append(replay.Inputs, ReplayInput{Tick: tick, Action: Action(action)})
+	}
+
+	return
+}
+
+// SaveReplay writes the replay to a file.
+func SaveReplay(filename string, replay Replay) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return replay.Write(file)
+}
+
+// LoadReplay reads a replay from a file. Replays from other versions are rejected, since they wouldn't play back the
+// same.
+func LoadReplay(filename string) (replay Replay, err error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	return ReadReplay(file)
+}
+
+// ReplayPlayer feeds the actions from a replay back to a game, tick by tick.
+type ReplayPlayer struct {
+	replay Replay
+	next   int // index of the next input to play
+}
+
+func NewReplayPlayer(replay Replay) ReplayPlayer {
+	return ReplayPlayer{replay: replay}
+}
+
+// Actions returns the actions that were applied on the provided tick. Ticks must be asked for in order.
+func (rp *ReplayPlayer) Actions(tick int) (actions []Action) {
+	for rp.next < len(rp.replay.Inputs) && rp.replay.Inputs[rp.next].Tick <= tick {
+		if rp.replay.Inputs[rp.next].Tick == tick {
+			actions = append(actions, rp.replay.Inputs[rp.next].Action)
+		}
+		rp.next += 1
+	}
+
+	return
+}
+
+// Done reports whether the replay has been played up to the provided tick.
+func (rp ReplayPlayer) Done(tick int) bool {
+	return tick >= rp.replay.Length
+}
+
+func (rp ReplayPlayer) Replay() Replay {
+	return rp.replay
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"github.com/bennicholls/tyumi/vec"
+)
+
+// Rotation states, in clockwise order from the spawn orientation.
+const (
+	ROT_0 int = iota // spawn state
+	ROT_R            // rotated clockwise once
+	ROT_2            // rotated 180 degrees
+	ROT_L            // rotated counter-clockwise once
+)
+
+// A RotationSystem decides where a piece ends up when it is rotated. When rotating, the game rotates the piece within
+// its bounding box and then tries each of the offsets ("kicks") returned by Kicks() in order, using the first one that
+// puts the piece in a valid position. If none fit, the rotation fails.
+type RotationSystem interface {
+	Kicks(piece PieceType, from, to int) []vec.Coord
+}
+
+// Preset rotation systems, by name.
+var RotationSystems = map[string]RotationSystem{
+	"srs":    SRS{},
+	"ars":    ARS{},
+	"simple": SimpleRotation{},
+}
+
+// SimpleRotation is the original TyTris rotation system. Pieces can only be kicked horizontally, and kicks don't
+// depend on the rotation being made.
+type SimpleRotation struct{}
+
+func (SimpleRotation) Kicks(piece PieceType, from, to int) []vec.Coord {
+	switch piece {
+	case O:
+		return []vec.Coord{{0, 0}}
+	case I:
+		return []vec.Coord{{0, 0}, {-1, 0}, {1, 0}, {-2, 0}, {2, 0}}
+	default:
+		return []vec.Coord{{0, 0}, {-1, 0}, {1, 0}}
+	}
+}
+
+// SRS is the Super Rotation System used by modern guideline tetris games. Each piece has its own set of kicks for
+// every rotation it can make, allowing floor kicks, T-spin triples and the rest. 180 degree rotations use the kick
+// table from SRS+, since standard SRS has no 180 degree rotations.
+type SRS struct{}
+
+func (SRS) Kicks(piece PieceType, from, to int) []vec.Coord {
+	switch piece {
+	case O:
+		return []vec.Coord{{0, 0}}
+	case I:
+		return srs_kicks_I[[2]int{from, to}]
+	default:
+		return srs_kicks_JLSTZ[[2]int{from, to}]
+	}
+}
+
+// SRS kick tables, indexed by [from, to] rotation states. NOTE: these are the standard tables with the y-axis flipped,
+// since in the well y increases downward.
+var srs_kicks_JLSTZ = map[[2]int][]vec.Coord{
+	{ROT_0, ROT_R}: {{0, 0}, {-1, 0}, {-1, -1}, {0, 2}, {-1, 2}},
+	{ROT_R, ROT_0}: {{0, 0}, {1, 0}, {1, 1}, {0, -2}, {1, -2}},
+	{ROT_R, ROT_2}: {{0, 0}, {1, 0}, {1, 1}, {0, -2}, {1, -2}},
+	{ROT_2, ROT_R}: {{0, 0}, {-1, 0}, {-1, -1}, {0, 2}, {-1, 2}},
+	{ROT_2, ROT_L}: {{0, 0}, {1, 0}, {1, -1}, {0, 2}, {1, 2}},
+	{ROT_L, ROT_2}: {{0, 0}, {-1, 0}, {-1, 1}, {0, -2}, {-1, -2}},
+	{ROT_L, ROT_0}: {{0, 0}, {-1, 0}, {-1, 1}, {0, -2}, {-1, -2}},
+	{ROT_0, ROT_L}: {{0, 0}, {1, 0}, {1, -1}, {0, 2}, {1, 2}},
+
+	{ROT_0, ROT_2}: {{0, 0}, {0, -1}, {1, -1}, {-1, -1}, {1, 0}, {-1, 0}},
+	{ROT_2, ROT_0}: {{0, 0}, {0, 1}, {-1, 1}, {1, 1}, {-1, 0}, {1, 0}},
+	{ROT_R, ROT_L}: {{0, 0}, {1, 0}, {1, -2}, {1, -1}, {0, -2}, {0, -1}},
+	{ROT_L, ROT_R}: {{0, 0}, {-1, 0}, {-1, -2}, {-1, -1}, {0, -2}, {0, -1}},
+}
+
+var srs_kicks_I = map[[2]int][]vec.Coord{
+	{ROT_0, ROT_R}: {{0, 0}, {-2, 0}, {1, 0}, {-2, 1}, {1, -2}},
+	{ROT_R, ROT_0}: {{0, 0}, {2, 0}, {-1, 0}, {2, -1}, {-1, 2}},
+	{ROT_R, ROT_2}: {{0, 0}, {-1, 0}, {2, 0}, {-1, -2}, {2, 1}},
+	{ROT_2, ROT_R}: {{0, 0}, {1, 0}, {-2, 0}, {1, 2}, {-2, -1}},
+	{ROT_2, ROT_L}: {{0, 0}, {2, 0}, {-1, 0}, {2, -1}, {-1, 2}},
+	{ROT_L, ROT_2}: {{0, 0}, {-2, 0}, {1, 0}, {-2, 1}, {1, -2}},
+	{ROT_L, ROT_0}: {{0, 0}, {1, 0}, {-2, 0}, {1, 2}, {-2, -1}},
+	{ROT_0, ROT_L}: {{0, 0}, {-1, 0}, {2, 0}, {-1, -2}, {2, 1}},
+
+	{ROT_0, ROT_2}: {{0, 0}, {0, -1}, {1, -1}, {-1, -1}, {1, 0}, {-1, 0}},
+	{ROT_2, ROT_0}: {{0, 0}, {0, 1}, {-1, 1}, {1, 1}, {-1, 0}, {1, 0}},
+	{ROT_R, ROT_L}: {{0, 0}, {1, 0}, {1, -2}, {1, -1}, {0, -2}, {0, -1}},
+	{ROT_L, ROT_R}: {{0, 0}, {-1, 0}, {-1, -2}, {-1, -1}, {0, -2}, {0, -1}},
+}
+
+// ARS is a TGM-style rotation system, as in the arcade games. A failed rotation is retried one cell to the right and
+// then one cell to the left, and the I piece never kicks. There are no floor kicks and no 180 degree kicks. NOTE: this
+// only copies ARS's kicks, pieces still use the SRS rotation states.
+type ARS struct{}
+
+func (ARS) Kicks(piece PieceType, from, to int) []vec.Coord {
+	switch piece {
+	case O, I:
+		return []vec.Coord{{0, 0}}
+	default:
+		if from == to+2 || to == from+2 {
+			return []vec.Coord{{0, 0}}
+		}
+		return []vec.Coord{{0, 0}, {1, 0}, {-1, 0}}
+	}
+}
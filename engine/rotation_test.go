@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bennicholls/tyumi/vec"
+)
+
+// filledRows describes a well full of garbage except for the provided cells, in the format Board.Load() reads.
+func filledRows(size vec.Dims, empty ...vec.Coord) []string {
+	rows := make([][]byte, size.H)
+	for y := range rows {
+		rows[y] = bytes.Repeat([]byte{'G'}, size.W)
+	}
+
+	for _, cell := range empty {
+		rows[cell.Y][cell.X] = '.'
+	}
+
+	lines := make([]string, size.H)
+	for y, row := range rows {
+		lines[y] = string(row)
+	}
+
+	return lines
+}
+
+func TestSRSKickTables(t *testing.T) {
+	for _, piece := range []PieceType{I, J, L, S, Z, T} {
+		for from := ROT_0; from <= ROT_L; from++ {
+			for to := ROT_0; to <= ROT_L; to++ {
+				if from == to {
+					continue
+				}
+
+				kicks := SRS{}.Kicks(piece, from, to)
+				if len(kicks) == 0 || kicks[0] != (vec.Coord{}) {
+					t.Errorf("%s %d->%d: kicks %v don't start with a plain rotation", piece.Letter(), from, to, kicks)
+				}
+			}
+		}
+	}
+}
+
+func TestSRSRotation(t *testing.T) {
+	size := DefaultConfig().WellSize
+
+	tests := []struct {
+		name      string
+		rows      []string
+		piece     Piece
+		dir       int
+		want      Piece
+		want_kick int
+		want_ok   bool
+	}{
+		{
+			name:    "open space",
+			piece:   Piece{Type: T, Rotation: ROT_0, Pos: vec.Coord{3, 10}},
+			dir:     CW,
+			want:    Piece{Type: T, Rotation: ROT_R, Pos: vec.Coord{3, 10}},
+			want_ok: true,
+		},
+		{
+			name:    "180 in open space",
+			piece:   Piece{Type: T, Rotation: ROT_0, Pos: vec.Coord{3, 10}},
+			dir:     ROTATE_180,
+			want:    Piece{Type: T, Rotation: ROT_2, Pos: vec.Coord{3, 10}},
+			want_ok: true,
+		},
+		{
+			name:    "O doesn't rotate",
+			piece:   Piece{Type: O, Rotation: ROT_0, Pos: vec.Coord{3, 10}},
+			dir:     CCW,
+			want:    Piece{Type: O, Rotation: ROT_0, Pos: vec.Coord{3, 10}},
+			want_ok: true,
+		},
+		{
+			name:      "I kicked off the right wall",
+			piece:     Piece{Type: I, Rotation: ROT_R, Pos: vec.Coord{7, 10}},
+			dir:       CW,
+			want:      Piece{Type: I, Rotation: ROT_2, Pos: vec.Coord{6, 10}},
+			want_kick: 1,
+			want_ok:   true,
+		},
+		{
+			name:      "I kicked off the left wall",
+			piece:     Piece{Type: I, Rotation: ROT_L, Pos: vec.Coord{-1, 10}},
+			dir:       CW,
+			want:      Piece{Type: I, Rotation: ROT_0, Pos: vec.Coord{0, 10}},
+			want_kick: 1,
+			want_ok:   true,
+		},
+		{
+			// only the last kick fits: 2 rows down and 1 left, into a t-spin triple slot
+			name: "T takes the fifth kick",
+			rows: filledRows(size,
+				vec.Coord{4, 19}, vec.Coord{3, 20}, vec.Coord{4, 20}, vec.Coord{5, 20}, // the T
+				vec.Coord{3, 21}, vec.Coord{3, 22}, vec.Coord{4, 22}, vec.Coord{3, 23}, // the slot
+			),
+			piece:     Piece{Type: T, Rotation: ROT_0, Pos: vec.Coord{3, 19}},
+			dir:       CW,
+			want:      Piece{Type: T, Rotation: ROT_R, Pos: vec.Coord{2, 21}},
+			want_kick: 4,
+			want_ok:   true,
+		},
+		{
+			name:    "nowhere to go",
+			rows:    filledRows(size, vec.Coord{3, 20}, vec.Coord{4, 20}, vec.Coord{5, 20}, vec.Coord{6, 20}),
+			piece:   Piece{Type: I, Rotation: ROT_0, Pos: vec.Coord{3, 19}},
+			dir:     CW,
+			want:    Piece{Type: I, Rotation: ROT_0, Pos: vec.Coord{3, 19}},
+			want_ok: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := setupGame(test.rows, test.piece.Type)
+			g.current_piece = test.piece
+			if !g.board.IsValidPosition(g.current_piece) {
+				t.Fatalf("%+v doesn't fit the board to start with", test.piece)
+			}
+
+			g.rotatePiece(test.dir)
+			if g.current_piece != test.want {
+				t.Errorf("rotated to %+v, want %+v", g.current_piece, test.want)
+			}
+			if g.last_move_was_rotation != test.want_ok {
+				t.Errorf("rotation succeeded: %v, want %v", g.last_move_was_rotation, test.want_ok)
+			}
+			if test.want_ok && g.last_kick_index != test.want_kick {
+				t.Errorf("used kick %d, want %d", g.last_kick_index, test.want_kick)
+			}
+		})
+	}
+}
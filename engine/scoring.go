@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// Clear describes a piece lock that cleared lines or performed a t-spin, for scoring purposes.
+type Clear struct {
+	Lines      int
+	TSpin      TSpin
+	Level      int
+	Combo      int  // number of consecutive clears before this one. 0 if the previous piece didn't clear anything
+	BackToBack bool // whether this is a difficult clear following another difficult clear
+}
+
+// Difficult clears are quads and t-spins that clear lines. Consecutive difficult clears are worth more in some
+// scoring systems.
+func (c Clear) IsDifficult() bool {
+	return c.Lines == 4 || (c.TSpin != TSPIN_NONE && c.Lines > 0)
+}
+
+// A ScoringSystem decides how many points things are worth.
+type ScoringSystem interface {
+	ClearPoints(clear Clear) int
+	DropPoints(cells int, hard_drop bool) int
+}
+
+// TableScoring is a ScoringSystem where points come from a fixed table of values. Line clear, t-spin and combo
+// points can be scaled by the current level, and difficult clears performed back-to-back can be given a multiplier.
+type TableScoring struct {
+	Lines        [5]int // points for clearing 0-4 lines
+	TSpin        [4]int // points for t-spins clearing 0-3 lines
+	TSpinMini    [3]int // points for mini t-spins clearing 0-2 lines
+	Combo        int    // points per combo count
+	BackToBack   float64
+	SoftDrop     int // points per cell soft dropped
+	HardDrop     int // points per cell hard dropped
+	ScaleByLevel bool
+}
+
+func (ts TableScoring) ClearPoints(clear Clear) (points int) {
+	switch clear.TSpin {
+	case TSPIN_FULL:
+		points = ts.TSpin[min(clear.Lines, len(ts.TSpin)-1)]
+	case TSPIN_MINI:
+		points = ts.TSpinMini[min(clear.Lines, len(ts.TSpinMini)-1)]
+	default:
+		points = ts.Lines[min(clear.Lines, len(ts.Lines)-1)]
+	}
+
+	if clear.BackToBack && ts.BackToBack != 0 {
+		points = int(float64(points) * ts.BackToBack)
+	}
+
+	points += ts.Combo * clear.Combo
+
+	if ts.ScaleByLevel {
+		points *= max(clear.Level, 1)
+	}
+
+	return
+}
+
+func (ts TableScoring) DropPoints(cells int, hard_drop bool) int {
+	if hard_drop {
+		return cells * ts.HardDrop
+	}
+
+	return cells * ts.SoftDrop
+}
+
+// Preset scoring systems, by name.
+var ScoringSystems = map[string]ScoringSystem{
+	"guideline": GuidelineScoring,
+	"nes":       NESScoring,
+	"master":    MasterScoring{},
+}
+
+// LoadScoring reads a custom scoring table from a json file. Anything left out of the file is worth nothing.
+func LoadScoring(filename string) (scoring TableScoring, err error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &scoring)
+	return
+}
+
+// Modern guideline scoring.
+var GuidelineScoring = TableScoring{
+	Lines:        [5]int{0, 100, 300, 500, 800},
+	TSpin:        [4]int{400, 800, 1200, 1600},
+	TSpinMini:    [3]int{100, 200, 400},
+	Combo:        50,
+	BackToBack:   1.5,
+	SoftDrop:     1,
+	HardDrop:     2,
+	ScaleByLevel: true,
+}
+
+// MasterScoring is based on TGM's scoring. Clears are worth the level (plus the lines cleared) divided by 4, times the
+// lines cleared, times the combo. T-spins don't count for anything special and only soft drops earn points.
+type MasterScoring struct{}
+
+func (ms MasterScoring) ClearPoints(clear Clear) int {
+	if clear.Lines == 0 {
+		return 0
+	}
+
+	return (clear.Level + clear.Lines + 3) / 4 * clear.Lines * (1 + clear.Combo)
+}
+
+func (ms MasterScoring) DropPoints(cells int, hard_drop bool) int {
+	if hard_drop {
+		return 0
+	}
+
+	return cells
+}
+
+// Scoring from the NES version. No t-spins, combos or hard drops there, so those are worth nothing (beyond the lines
+// they clear).
+var NESScoring = TableScoring{
+	Lines:        [5]int{0, 40, 100, 300, 1200},
+	TSpin:        [4]int{0, 40, 100, 300},
+	TSpinMini:    [3]int{0, 40, 100},
+	SoftDrop:     1,
+	ScaleByLevel: true,
+}
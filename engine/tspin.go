@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"github.com/bennicholls/tyumi/vec"
+)
+
+type TSpin int
+
+const (
+	TSPIN_NONE TSpin = iota
+	TSPIN_MINI
+	TSPIN_FULL
+)
+
+// corners of the T piece's 3x3 bounding box, and which 2 of them are in front of the piece (on the side the T points
+// to) for each rotation state.
+var tspin_corners = [4]vec.Coord{{0, 0}, {2, 0}, {2, 2}, {0, 2}}
+var tspin_front_corners = [4][2]int{
+	ROT_0: {0, 1},
+	ROT_R: {1, 2},
+	ROT_2: {2, 3},
+	ROT_L: {3, 0},
+}
+
+// detectTSpin checks whether the current piece is in a t-spin position, using the 3-corner rule: the piece must be a
+// T, its last successful action must have been a rotation, and at least 3 of the corners around its center must be
+// filled by blocks or the walls/floor of the well. If both front corners are filled it's a full t-spin, otherwise
+// it's a mini, unless a quarter turn needed the last SRS kick (the one that makes t-spin triples possible), which
+// always counts as a full t-spin. 180 degree turns have their own kicks, so the rule doesn't apply to them.
+func (g *Game) detectTSpin() TSpin {
+	if g.current_piece.Type != T || !g.last_move_was_rotation {
+		return TSPIN_NONE
+	}
+
+	var filled [4]bool
+	var filled_count int
+	for i, corner := range tspin_corners {
+		pos := g.current_piece.Pos.Add(corner)
+		if pos.Y >= 0 && (!pos.IsInside(g.board.Size()) || g.board.Block(pos) != NO_PIECE) {
+			filled[i] = true
+			filled_count += 1
+		}
+	}
+
+	if filled_count < 3 {
+		return TSPIN_NONE
+	}
+
+	front := tspin_front_corners[g.current_piece.Rotation]
+	if (filled[front[0]] && filled[front[1]]) || (g.last_kick_index == 4 && g.last_rotation != ROTATE_180) {
+		return TSPIN_FULL
+	}
+
+	return TSPIN_MINI
+}
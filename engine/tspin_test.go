@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/bennicholls/tyumi/vec"
+)
+
+// a t-spin double slot at the bottom of the well, with an overhang over its top left corner
+var tsd_slot = []string{
+	"...G......",
+	"GGG...GGGG",
+	"GGGG.GGGGG",
+}
+
+func TestDetectTSpin(t *testing.T) {
+	slot := vec.Coord{3, DefaultConfig().WellSize.H - 3} // top-left of the T's bounding box in the slot
+
+	tests := []struct {
+		name    string
+		rows    []string
+		piece   Piece
+		rotated bool // whether the last move was a rotation
+		dir     int  // direction of the last rotation
+		kick    int  // index of the kick the last rotation used
+		want    TSpin
+	}{
+		{"both front corners", tsd_slot, Piece{Type: T, Rotation: ROT_2, Pos: slot}, true, CW, 0, TSPIN_FULL},
+		{"one front corner", tsd_slot, Piece{Type: T, Rotation: ROT_0, Pos: slot}, true, CW, 0, TSPIN_MINI},
+		{"fifth kick makes it full", tsd_slot, Piece{Type: T, Rotation: ROT_0, Pos: slot}, true, CW, 4, TSPIN_FULL},
+		{"fifth kick counter-clockwise", tsd_slot, Piece{Type: T, Rotation: ROT_0, Pos: slot}, true, CCW, 4, TSPIN_FULL},
+		{"fifth kick of a 180 doesn't count", tsd_slot, Piece{Type: T, Rotation: ROT_0, Pos: slot}, true, ROTATE_180, 4, TSPIN_MINI},
+		{"180 into the slot", tsd_slot, Piece{Type: T, Rotation: ROT_2, Pos: slot}, true, ROTATE_180, 1, TSPIN_FULL},
+		{"moved after rotating", tsd_slot, Piece{Type: T, Rotation: ROT_2, Pos: slot}, false, CW, 0, TSPIN_NONE},
+		{"not a T", tsd_slot, Piece{Type: S, Rotation: ROT_0, Pos: slot}, true, CW, 0, TSPIN_NONE},
+		{"only 2 corners", tsd_slot[1:], Piece{Type: T, Rotation: ROT_2, Pos: slot}, true, CW, 0, TSPIN_NONE},
+		{"walls are corners", []string{"..........", ".G........"}, Piece{Type: T, Rotation: ROT_R, Pos: vec.Coord{-1, slot.Y}}, true, CW, 0, TSPIN_MINI},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := setupGame(test.rows, test.piece.Type)
+			g.current_piece = test.piece
+			if !g.board.IsValidPosition(g.current_piece) {
+				t.Fatalf("%+v doesn't fit the board", test.piece)
+			}
+
+			g.last_move_was_rotation = test.rotated
+			g.last_rotation = test.dir
+			g.last_kick_index = test.kick
+
+			if tspin := g.detectTSpin(); tspin != test.want {
+				t.Errorf("got t-spin %d, want %d", tspin, test.want)
+			}
+		})
+	}
+}
+
+// a t-spin triple played for real: the T takes the fifth kick into the slot and clears 3 lines
+func TestTSpinTriple(t *testing.T) {
+	rows := []string{
+		"GGGG......",
+		"GGG...GGGG",
+		"GGG.GGGGGG",
+		"GGG..GGGGG",
+		"GGG.GGGGGG",
+		"GGG.GGGGGG",
+	}
+	g := setupGame(rows, T, O)
+	g.Step(nil)
+
+	// hang the T (pointing up) over the slot, then rotate it in
+	h := g.Board().Size().H
+	g.current_piece.Pos = vec.Coord{3, h - 6}
+	g.current_piece.Rotation = ROT_0
+	g.Step([]Action{ACTION_ROTATE_CW})
+
+	if g.last_kick_index != 4 {
+		t.Fatalf("rotation used kick %d, want 4", g.last_kick_index)
+	}
+
+	events := g.Step([]Action{ACTION_HARD_DROP})
+	for _, event := range events {
+		if event.Type != EV_PIECE_LOCKED {
+			continue
+		}
+
+		if event.TSpin != TSPIN_FULL || len(event.Lines) != 3 {
+			t.Errorf("locked %+v, want a full t-spin clearing 3 lines", event)
+		}
+		if g.Info().TSpinTriples != 1 {
+			t.Errorf("info after the t-spin triple: %+v", g.Info())
+		}
+		return
+	}
+
+	t.Fatalf("hard drop fired %+v, want the piece to lock", events)
+}
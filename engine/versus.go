@@ -0,0 +1,104 @@
+package engine
+
+// AttackTable decides how many lines of garbage a clear sends to an opponent. The zero table never attacks.
+type AttackTable struct {
+	Lines        [5]int // lines sent for clearing 0-4 lines
+	TSpin        [4]int // lines sent for t-spins clearing 0-3 lines
+	TSpinMini    [3]int // lines sent for mini t-spins clearing 0-2 lines
+	BackToBack   int    // extra lines sent for a back-to-back difficult clear
+	Combo        []int  // extra lines sent for each combo count. the last value is used for longer combos
+	PerfectClear int    // extra lines sent for leaving the well empty
+}
+
+// GuidelineAttack is the attack table from modern guideline versus games.
+var GuidelineAttack = AttackTable{
+	Lines:        [5]int{0, 0, 1, 2, 4},
+	TSpin:        [4]int{0, 2, 4, 6},
+	TSpinMini:    [3]int{0, 0, 1},
+	BackToBack:   1,
+	Combo:        []int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4, 5},
+	PerfectClear: 10,
+}
+
+// Attack returns the number of lines of garbage sent for a clear.
+func (at AttackTable) Attack(clear Clear, perfect_clear bool) (lines int) {
+	if clear.Lines == 0 {
+		return 0
+	}
+
+	switch clear.TSpin {
+	case TSPIN_FULL:
+		lines = at.TSpin[min(clear.Lines, len(at.TSpin)-1)]
+	case TSPIN_MINI:
+		lines = at.TSpinMini[min(clear.Lines, len(at.TSpinMini)-1)]
+	default:
+		lines = at.Lines[min(clear.Lines, len(at.Lines)-1)]
+	}
+
+	if clear.BackToBack {
+		lines += at.BackToBack
+	}
+
+	if len(at.Combo) > 0 {
+		lines += at.Combo[min(clear.Combo, len(at.Combo)-1)]
+	}
+
+	if perfect_clear {
+		lines += at.PerfectClear
+	}
+
+	return
+}
+
+// receiveGarbage queues up lines of garbage sent by an opponent. Lines received on the same tick arrive as a single
+// attack, and share a hole.
+func (g *Game) receiveGarbage(lines int) {
+	if len(g.incoming_garbage) > 0 && g.incoming_tick == g.info.Time {
+		g.incoming_garbage[len(g.incoming_garbage)-1] += lines
+	} else {
+		g.incoming_garbage = append(g.incoming_garbage, lines)
+	}
+
+	g.incoming_tick = g.info.Time
+}
+
+// updateAttack runs after a piece locks. Clears send attacks, which cancel out incoming garbage first, and anything
+// left over goes to the opponent. If the piece didn't clear anything the incoming garbage rises, one attack at a time.
+func (g *Game) updateAttack(clear Clear, perfect_clear bool) {
+	if clear.Lines == 0 {
+		for _, lines := range g.incoming_garbage {
+			g.garbage_hole = g.garbage_rng.Intn(g.board.Size().W)
+			g.info.GarbageReceived += lines
+			g.raiseGarbage(lines)
+			if g.IsOver() {
+				break
+			}
+		}
+		g.incoming_garbage = g.incoming_garbage[:0]
+		return
+	}
+
+	attack := g.config.Attack.Attack(clear, perfect_clear)
+	for attack > 0 && len(g.incoming_garbage) > 0 {
+		cancelled := min(attack, g.incoming_garbage[0])
+		attack -= cancelled
+		g.incoming_garbage[0] -= cancelled
+		if g.incoming_garbage[0] == 0 {
+			g.incoming_garbage = g.incoming_garbage[1:]
+		}
+	}
+
+	if attack > 0 {
+		g.info.GarbageSent += attack
+		g.fireEvent(Event{Type: EV_ATTACK, Attack: attack})
+	}
+}
+
+// IncomingGarbage returns the number of lines of garbage waiting to rise.
+func (g *Game) IncomingGarbage() (lines int) {
+	for _, attack := range g.incoming_garbage {
+		lines += attack
+	}
+
+	return
+}
@@ -1,141 +1,125 @@
 package main
 
 import (
+	"github.com/bennicholls/tytris/engine"
+	"github.com/bennicholls/tyumi"
 	"github.com/bennicholls/tyumi/event"
-	"github.com/bennicholls/tyumi/gfx/col"
-	"github.com/bennicholls/tyumi/gfx/ui"
 	"github.com/bennicholls/tyumi/input"
 	"github.com/bennicholls/tyumi/vec"
 )
 
-// input handler for when we're playing
-func (t *TyTris) handleInput_playing(event event.Event) (event_handled bool) {
-	if event.ID() == input.EV_KEYBOARD {
-		key_event := event.(*input.KeyboardEvent)
-		switch key_event.PressType {
-		case input.KEY_PRESSED:
-			switch key_event.Direction() {
-			case vec.DIR_LEFT:
-				t.movePiece(vec.DIR_LEFT)
-				event_handled = true
-			case vec.DIR_RIGHT:
-				t.movePiece(vec.DIR_RIGHT)
-				event_handled = true
-			case vec.DIR_UP:
-				t.dropPiece()
-				event_handled = true
-			case vec.DIR_DOWN:
-				t.speed_up = true
-				event_handled = true
-			}
-
-			switch key_event.Key {
-			case input.K_z:
-				t.rotatePiece(CCW)
-				event_handled = true
-			case input.K_c:
-				t.rotatePiece(CW)
-				event_handled = true
-			case input.K_x:
-				t.swap_held_piece()
-				event_handled = true
-			case input.K_ESCAPE:
-				fireStateChangeEvent(PAUSED)
-			}
-		case input.KEY_RELEASED:
-			if key_event.Direction() == vec.DIR_DOWN {
-				t.speed_up = false
-				event_handled = true
-			}
-		}
-	}
-
-	return
+// KeyMap is the set of keys a player controls their pieces with.
+type KeyMap struct {
+	Left, Right, HardDrop, SoftDrop      input.Keycode
+	RotateCW, RotateCCW, Rotate180, Hold input.Keycode
 }
 
-func (t *TyTris) rotatePiece(dir int) {
-	if t.current_piece.pType == NO_PIECE {
+// keys for a single player
+var solo_keys = KeyMap{input.K_LEFT, input.K_RIGHT, input.K_UP, input.K_DOWN, input.K_c, input.K_z, input.K_a, input.K_x}
+
+// keys for local versus games. the left player gets WASD and the right player gets the arrows.
+var left_keys = KeyMap{input.K_a, input.K_d, input.K_w, input.K_s, input.K_e, input.K_q, input.K_r, input.K_f}
+var right_keys = KeyMap{input.K_LEFT, input.K_RIGHT, input.K_UP, input.K_DOWN, input.K_PERIOD, input.K_COMMA, input.K_l, input.K_SLASH}
+
+// action returns the game action for pressing or releasing a key. ok is false if the key doesn't do anything.
+func (km KeyMap) action(key input.Keycode, press input.KeyPressType) (action engine.Action, ok bool) {
+	if press == input.KEY_RELEASED {
+		switch key {
+		case km.Left:
+			return engine.ACTION_RELEASE_LEFT, true
+		case km.Right:
+			return engine.ACTION_RELEASE_RIGHT, true
+		case km.SoftDrop:
+			return engine.ACTION_SOFT_DROP_OFF, true
+		}
 		return
 	}
 
-	kick, ok := t.testRotate(dir)
-	if !ok {
-		return
+	switch key {
+	case km.Left:
+		return engine.ACTION_MOVE_LEFT, true
+	case km.Right:
+		return engine.ACTION_MOVE_RIGHT, true
+	case km.HardDrop:
+		return engine.ACTION_HARD_DROP, true
+	case km.SoftDrop:
+		return engine.ACTION_SOFT_DROP_ON, true
+	case km.RotateCCW:
+		return engine.ACTION_ROTATE_CCW, true
+	case km.RotateCW:
+		return engine.ACTION_ROTATE_CW, true
+	case km.Rotate180:
+		return engine.ACTION_ROTATE_180, true
+	case km.Hold:
+		return engine.ACTION_HOLD, true
 	}
 
-	t.current_piece.Rotate(dir)
-	t.current_piece.pos.Move(kick.X, kick.Y)
-	t.updateGhost()
-	ui.GetLabelled[*PieceElement](t.Window(), "current piece").UpdatePiece(t.current_piece)
-
-	sounds.Play("rotate")
+	return
 }
 
-func (t *TyTris) movePiece(dir vec.Direction) {
-	if t.current_piece.pType == NO_PIECE {
+// input handler for when we're playing. inputs are translated into game actions for whichever player the key belongs
+// to, which are applied on the next update.
+func (t *TyTris) handleInput_playing(event event.Event) (event_handled bool) {
+	if event.ID() != input.EV_KEYBOARD {
 		return
 	}
 
-	if !t.testMove(dir) {
-		return
+	key_event := event.(*input.KeyboardEvent)
+	if key_event.Repeat { // held keys are handled by the game's auto-shift, so ignore the OS's key repeats
+		return true
 	}
 
-	t.current_piece.pos.Move(dir.X, dir.Y)
-	t.updateGhost()
-	ui.GetLabelled[*PieceElement](t.Window(), "current piece").UpdatePiece(t.current_piece)
-	if dir != vec.DIR_DOWN {
-		sounds.Play("move")
+	if key_event.Key == input.K_ESCAPE && key_event.PressType == input.KEY_PRESSED {
+		fireStateChangeEvent(PAUSED)
+		return
 	}
-}
 
-func (t *TyTris) dropPiece() {
-	if t.current_piece.pType == NO_PIECE {
-		return
+	for _, player := range t.players {
+		if player.handleKey(key_event) {
+			return true
+		}
 	}
 
-	t.current_piece.pos = t.ghost_position
-	t.dropped_piece = true
-	t.lockPiece()
-	t.info.quick_drops += 1
+	return
 }
 
-func (t *TyTris) swap_held_piece() {
-	if t.current_piece.pType == NO_PIECE {
-		return
-	}
-
-	if t.held_piece.pType == t.current_piece.pType {
+// input handler for when we're watching a replay. the game gets its actions from the replay, so the keys control
+// playback instead.
+func (t *TyTris) handleInput_replay(event event.Event) (event_handled bool) {
+	if event.ID() != input.EV_KEYBOARD {
 		return
 	}
 
-	if t.swapped_piece {
+	key_event := event.(*input.KeyboardEvent)
+	if key_event.PressType != input.KEY_PRESSED {
 		return
 	}
 
-	if t.held_piece.pType == NO_PIECE {
-		t.held_piece = Piece{pType: t.current_piece.pType}
-		t.spawn_piece(t.get_next_piece())
-	} else {
-		held := t.held_piece
-		t.held_piece = Piece{pType: t.current_piece.pType}
-		t.spawn_piece(held)
+	switch key_event.Direction() {
+	case vec.DIR_LEFT:
+		t.changeReplaySpeed(-1)
+		return true
+	case vec.DIR_RIGHT:
+		t.changeReplaySpeed(1)
+		return true
 	}
 
-	t.swapped_piece = true
-	t.heldArea.Updated = true
-
-	held_element := ui.GetLabelled[*PieceElement](t.Window(), "held")
-	held_element.UpdatePiece(t.held_piece)
-	if t.held_piece.pType == O {
-		held_element.MoveTo(vec.Coord{2, 1})
-	} else {
-		held_element.MoveTo(vec.Coord{1, 1})
+	switch key_event.Key {
+	case input.K_SPACE:
+		t.toggleReplayPause()
+		event_handled = true
+	case input.K_PERIOD: // frame step
+		if t.replay_paused {
+			t.stepReplay()
+		}
+		event_handled = true
+	case input.K_ESCAPE:
+		if t.replay_paused {
+			tyumi.ResumeMusic()
+		}
+		fireStateChangeEvent(GAME_START)
+		event_handled = true
 	}
 
-	colour := t.held_piece.Colour()
-	t.held_flash.ToColours = col.Pair{colour, colour}
-	t.held_flash.Play()
-	sounds.Play("swap")
-
-	t.info.swaps += 1
+	return
 }
@@ -10,7 +10,6 @@ import (
 
 var LDA_Duration int = 20
 
-var SUA_Sweep_Duration int = (well_size.H)
 var SUA_Particle_Decay int = 15
 
 type LineDestroyAnimation struct {
@@ -55,13 +54,16 @@ func (lda *LineDestroyAnimation) Render(canvas *gfx.Canvas) {
 type SpeedUpAnimation struct {
 	gfx.Animation
 
-	particles    []SweepParticle
-	particle_vis gfx.Visuals
+	particles      []SweepParticle
+	particle_vis   gfx.Visuals
+	sweep_duration int
 }
 
-func NewSpeedUpAnimation() (sa SpeedUpAnimation) {
+// Creates a speedup animation. The sweep takes 1 tick per row, so pass in the height of the area being swept.
+func NewSpeedUpAnimation(height int) (sa SpeedUpAnimation) {
 	sa.OneShot = true
-	sa.Duration = SUA_Particle_Decay + SUA_Sweep_Duration
+	sa.sweep_duration = height
+	sa.Duration = SUA_Particle_Decay + sa.sweep_duration
 	sa.AlwaysUpdates = true
 
 	sa.particle_vis = gfx.NewGlyphVisuals(gfx.GLYPH_ARROW_UP, col.Pair{col.LIGHTGREY, col.DARKGREY})
@@ -73,7 +75,7 @@ func NewSpeedUpAnimation() (sa SpeedUpAnimation) {
 
 func (sa *SpeedUpAnimation) Render(canvas *gfx.Canvas) {
 	area := canvas.Bounds()
-	progress := float64(sa.GetTicks()) / float64(SUA_Sweep_Duration)
+	progress := float64(sa.GetTicks()) / float64(sa.sweep_duration)
 	y := area.H - 1 - int(progress*float64(area.H))
 
 	//draw active particles
@@ -87,7 +89,7 @@ func (sa *SpeedUpAnimation) Render(canvas *gfx.Canvas) {
 		}
 	}
 
-	if sa.GetTicks() > SUA_Sweep_Duration {
+	if sa.GetTicks() > sa.sweep_duration {
 		return
 	}
 
@@ -6,6 +6,9 @@ import (
 
 var EV_CHANGESTATE int = event.Register("State Change")
 var EV_HIGHSCORE int = event.Register("High Score Recorded!")
+var EV_PLAYREPLAY int = event.Register("Play Replay")
+var EV_NEWGAME int = event.Register("New Game")
+var EV_VERSUS int = event.Register("Versus")
 
 type stateChangeEvent struct {
 	event.EventPrototype
@@ -25,20 +28,74 @@ func fireStateChangeEvent(new_state int) {
 type highScoreEvent struct {
 	event.EventPrototype
 
-	name  string
-	score int
+	mode  Mode
+	entry HighScoreEntry
 }
 
-func fireHighScoreEvent(name string, score int) {
+func fireHighScoreEvent(mode Mode, entry HighScoreEntry) {
 	hse := highScoreEvent{
 		EventPrototype: *event.New(EV_HIGHSCORE),
-		name:           name,
-		score:          score,
+		mode:           mode,
+		entry:          entry,
 	}
 
 	event.Fire(&hse)
 }
 
+type newGameEvent struct {
+	event.EventPrototype
+
+	mode Mode
+}
+
+func fireNewGameEvent(mode Mode) {
+	nge := newGameEvent{
+		EventPrototype: *event.New(EV_NEWGAME),
+		mode:           mode,
+	}
+
+	event.Fire(&nge)
+}
+
+type playReplayEvent struct {
+	event.EventPrototype
+
+	name string
+}
+
+func firePlayReplayEvent(name string) {
+	pre := playReplayEvent{
+		EventPrototype: *event.New(EV_PLAYREPLAY),
+		name:           name,
+	}
+
+	event.Fire(&pre)
+}
+
+// things the player can ask for from the versus menu
+const (
+	VERSUS_HOST int = iota
+	VERSUS_JOIN
+	VERSUS_CANCEL
+)
+
+type versusEvent struct {
+	event.EventPrototype
+
+	request int    // one of the constants above
+	address string // for VERSUS_JOIN
+}
+
+func fireVersusEvent(request int, address string) {
+	ve := versusEvent{
+		EventPrototype: *event.New(EV_VERSUS),
+		request:        request,
+		address:        address,
+	}
+
+	event.Fire(&ve)
+}
+
 func (t *TyTris) handle_event(event event.Event) (event_handled bool) {
 	switch event.ID() {
 	case EV_CHANGESTATE:
@@ -46,8 +103,25 @@ func (t *TyTris) handle_event(event event.Event) (event_handled bool) {
 		t.changeState(e.new_state)
 	case EV_HIGHSCORE:
 		e := event.(*highScoreEvent)
-		t.highScores.AddEntry(HighScoreEntry{Name: e.name, Score: e.score})
-		t.highScoreArea.UpdateScores(t.highScores)
+		t.highScores.AddEntry(e.mode, e.entry)
+		t.highScoreArea.UpdateScores(t.highScores, t.mode)
+	case EV_NEWGAME:
+		e := event.(*newGameEvent)
+		t.setMode(e.mode)
+		t.changeState(NEW_GAME)
+	case EV_PLAYREPLAY:
+		e := event.(*playReplayEvent)
+		t.startReplay(e.name)
+	case EV_VERSUS:
+		e := event.(*versusEvent)
+		switch e.request {
+		case VERSUS_HOST:
+			t.hostVersus()
+		case VERSUS_JOIN:
+			t.joinVersus(e.address)
+		case VERSUS_CANCEL:
+			t.cancelVersus()
+		}
 	}
 
 	return
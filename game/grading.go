@@ -0,0 +1,66 @@
+package main
+
+import (
+	"github.com/bennicholls/tytris/engine"
+)
+
+// grades for master mode from worst to best, with the score needed to earn each one
+var master_grades = []struct {
+	name  string
+	score int
+}{
+	{"9", 0}, {"8", 400}, {"7", 800}, {"6", 1400}, {"5", 2000}, {"4", 3500}, {"3", 5500}, {"2", 8000}, {"1", 12000},
+	{"S1", 16000}, {"S2", 22000}, {"S3", 30000}, {"S4", 40000}, {"S5", 52000}, {"S6", 66000}, {"S7", 82000},
+	{"S8", 100000}, {"S9", 120000},
+}
+
+// to earn the Grand Master grade, the player has to reach each of these levels with at least this score, in this many
+// ticks or less
+var master_checkpoints = []struct {
+	level, score, time int
+}{
+	{300, 12000, (4*60 + 15) * 60},
+	{500, 40000, (7*60 + 30) * 60},
+	{master_max_level, 126000, (13*60 + 30) * 60},
+}
+
+// MasterGrader keeps track of the player's grade during a master game, checking whether they're still on course for
+// Grand Master as they pass each checkpoint.
+type MasterGrader struct {
+	checkpoint int  // index of the next checkpoint to pass
+	eligible   bool // false once a checkpoint has been missed
+}
+
+func (mg *MasterGrader) Reset() {
+	mg.checkpoint = 0
+	mg.eligible = true
+}
+
+// Update checks any checkpoints the player has reached since the last update.
+func (mg *MasterGrader) Update(info engine.Info) {
+	for ; mg.checkpoint < len(master_checkpoints); mg.checkpoint++ {
+		cp := master_checkpoints[mg.checkpoint]
+		if info.Level < cp.level {
+			return
+		}
+
+		if info.Score < cp.score || info.Time > cp.time {
+			mg.eligible = false
+		}
+	}
+}
+
+// Grade returns the grade the player has earned so far.
+func (mg MasterGrader) Grade(info engine.Info) (grade string) {
+	if mg.eligible && mg.checkpoint == len(master_checkpoints) {
+		return "GM"
+	}
+
+	for _, g := range master_grades {
+		if info.Score >= g.score {
+			grade = g.name
+		}
+	}
+
+	return
+}
@@ -11,40 +11,75 @@ import (
 var highscore_filename string = "high.scores"
 
 type HighScores struct {
-	Scores []HighScoreEntry
+	Boards map[string][]HighScoreEntry // top 10 lists for each mode, by leaderboard name
+
+	Scores []HighScoreEntry // endless scores from before there were other modes. moved to the endless board on load
+}
+
+// Board returns the leaderboard for the provided mode.
+func (hs HighScores) Board(mode Mode) []HighScoreEntry {
+	return hs.Boards[mode.Leaderboard()]
 }
 
-func (hs HighScores) IsHighScore(score int) bool {
-	if score == 0 {
+// Best returns the best result recorded for the provided mode, if there is one.
+func (hs HighScores) Best(mode Mode) (best HighScoreEntry, ok bool) {
+	board := hs.Board(mode)
+	if len(board) == 0 {
+		return
+	}
+
+	return board[0], true
+}
+
+func (hs HighScores) IsHighScore(mode Mode, entry HighScoreEntry) bool {
+	if mode.Leaderboard() == "" {
+		return false
+	}
+
+	if mode.RankedByTime() {
+		if entry.Time == 0 {
+			return false
+		}
+	} else if entry.Score == 0 {
 		return false
 	}
 
-	if len(hs.Scores) < 10 {
+	board := hs.Board(mode)
+	if len(board) < 10 {
 		return true
 	}
 
-	return score > hs.Scores[len(hs.Scores)-1].Score
+	return compareEntries(mode, entry, board[len(board)-1]) < 0
 }
 
-func (hs *HighScores) AddEntry(entry HighScoreEntry) {
-	hs.Scores = append(hs.Scores, entry)
-	slices.SortFunc(hs.Scores, func(e1, e2 HighScoreEntry) int {
-		if e1.Score > e2.Score {
-			return -1
-		} else if e1.Score < e2.Score {
-			return 1
-		} else {
-			return 0
-		}
+func (hs *HighScores) AddEntry(mode Mode, entry HighScoreEntry) {
+	if hs.Boards == nil {
+		hs.Boards = make(map[string][]HighScoreEntry)
+	}
+
+	board := append(hs.Board(mode), entry)
+	slices.SortStableFunc(board, func(e1, e2 HighScoreEntry) int {
+		return compareEntries(mode, e1, e2)
 	})
 
-	if len(hs.Scores) > 10 {
-		hs.Scores = hs.Scores[0:10]
+	if len(board) > 10 {
+		board = board[0:10]
 	}
+
+	hs.Boards[mode.Leaderboard()] = board
+}
+
+// compareEntries orders entries from best to worst: highest score first, or fastest time first for timed modes.
+func compareEntries(mode Mode, e1, e2 HighScoreEntry) int {
+	if mode.RankedByTime() {
+		return e1.Time - e2.Time
+	}
+
+	return e2.Score - e1.Score
 }
 
 func (hs *HighScores) WriteToDisk() {
-	if len(hs.Scores) == 0 {
+	if len(hs.Boards) == 0 {
 		return
 	}
 
@@ -61,7 +96,7 @@ func (hs *HighScores) WriteToDisk() {
 }
 
 func (hs *HighScores) LoadFromDisk() {
-	hs.Scores = make([]HighScoreEntry, 0)
+	hs.Boards = make(map[string][]HighScoreEntry)
 
 	file, err := os.Open(highscore_filename)
 	if err != nil {
@@ -76,12 +111,17 @@ func (hs *HighScores) LoadFromDisk() {
 		log.Warning("Could not decode high score file. How bizarre ;)")
 	}
 
-	if len(dhs.Scores) > 0 {
-		hs.Scores = dhs.Scores
+	for name, board := range dhs.Boards {
+		hs.Boards[name] = board
+	}
+
+	if len(dhs.Scores) > 0 && len(hs.Boards["endless"]) == 0 {
+		hs.Boards["endless"] = dhs.Scores
 	}
 }
 
 type HighScoreEntry struct {
 	Name  string
 	Score int
+	Time  int // in ticks, for modes ranked by time
 }
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bennicholls/tytris/engine"
+	"github.com/bennicholls/tyumi/log"
+	"github.com/bennicholls/tyumi/vec"
+)
+
+// GameMode is one of the ways TyTris can be played.
+type GameMode int
+
+const (
+	MODE_ENDLESS  GameMode = iota // play until you top out
+	MODE_SPRINT                   // clear a number of lines as fast as possible
+	MODE_ULTRA                    // score as many points as possible before the time runs out
+	MODE_MARATHON                 // clear a long line goal, getting faster every level
+	MODE_DIG                      // dig through rows of garbage as fast as possible
+	MODE_SURVIVAL                 // survive as garbage rises from the bottom, faster and faster
+	MODE_PUZZLE                   // complete an objective from a set starting position with a fixed queue of pieces
+	MODE_MASTER                   // survive all the way to 20G and level 999, and get graded on the way
+	MODE_VERSUS                   // send garbage to an opponent over the network until one of you tops out
+)
+
+// modes in the order they're listed in the menu. versus games are started from their own menu, so they aren't here
+var game_modes = []GameMode{MODE_ENDLESS, MODE_SPRINT, MODE_ULTRA, MODE_MARATHON, MODE_DIG, MODE_SURVIVAL, MODE_MASTER, MODE_PUZZLE}
+
+// line goals the player can choose from for a sprint
+var sprint_goals = []int{20, 40, 100}
+
+// time limits the player can choose from for ultra, in minutes
+var ultra_minutes = []int{1, 2, 3, 5}
+
+// line goals the player can choose from for a marathon, along with the one in the settings. the level is capped at
+// whatever level the goal finishes on
+var marathon_goals = []int{150, 200}
+
+// rows of garbage the player can choose to dig through. the well needs to be tall enough to fit them
+var dig_rows = []int{10, 18}
+
+// garbage settings for dig mode. tidy garbage mostly lines its holes up, messy garbage moves the hole every row
+const (
+	dig_tidy_messiness    float64 = 0.25
+	dig_messy_messiness   float64 = 1
+	dig_endless_messiness float64 = 0.5
+	dig_endless_rows      int     = 5
+	dig_endless_interval  int     = 4 * 60 // ticks between rows rising in endless dig
+)
+
+// master mode ends when this level is reached
+const master_max_level int = 999
+
+// big mode draws every block as a big_scale x big_scale square. the well needs to be at least big_min_well_width wide
+// for the pieces to fit.
+const (
+	big_scale          int = 2
+	big_min_well_width int = 4 * big_scale
+)
+
+// garbage settings for survival mode. how fast the garbage rises is decided by the rise curve in the settings.
+const (
+	survival_start_rows int     = 3
+	survival_messiness  float64 = 0.5
+)
+
+func (gm GameMode) String() string {
+	switch gm {
+	case MODE_SPRINT:
+		return "Sprint"
+	case MODE_ULTRA:
+		return "Ultra"
+	case MODE_MARATHON:
+		return "Marathon"
+	case MODE_DIG:
+		return "Dig"
+	case MODE_SURVIVAL:
+		return "Survival"
+	case MODE_PUZZLE:
+		return "Puzzle"
+	case MODE_MASTER:
+		return "Master"
+	case MODE_VERSUS:
+		return "Versus"
+	default:
+		return "Endless"
+	}
+}
+
+// Options returns the variations of the mode the player can choose between. Modes without any options just return
+// the one. Puzzle mode returns one option for each puzzle pack, which might be none at all. marathon_goal is the line
+// goal from the settings, offered alongside the usual marathon goals. 0 offers only those.
+func (gm GameMode) Options(marathon_goal int) (options []Mode) {
+	switch gm {
+	case MODE_SPRINT:
+		for _, goal := range sprint_goals {
+			options = append(options, Mode{Type: MODE_SPRINT, Goal: goal})
+		}
+	case MODE_ULTRA:
+		for _, minutes := range ultra_minutes {
+			options = append(options, Mode{Type: MODE_ULTRA, Minutes: minutes})
+		}
+	case MODE_MARATHON:
+		goals := marathon_goals
+		if marathon_goal > 0 && !slices.Contains(goals, marathon_goal) {
+			goals = append(slices.Clone(goals), marathon_goal)
+			slices.Sort(goals)
+		}
+
+		for _, endless := range []bool{false, true} {
+			for _, goal := range goals {
+				options = append(options, Mode{Type: MODE_MARATHON, Goal: goal, Endless: endless})
+			}
+		}
+	case MODE_DIG:
+		for _, rows := range dig_rows {
+			options = append(options, Mode{Type: MODE_DIG, Goal: rows}, Mode{Type: MODE_DIG, Goal: rows, Messy: true})
+		}
+		options = append(options, Mode{Type: MODE_DIG, Endless: true})
+	case MODE_PUZZLE:
+		for _, pack := range listPuzzlePacks() {
+			options = append(options, Mode{Type: MODE_PUZZLE, Pack: pack})
+		}
+	default:
+		options = append(options, Mode{Type: gm})
+	}
+
+	return
+}
+
+// StackModifier makes a mode harder by hiding the stack from the player.
+type StackModifier int
+
+const (
+	STACK_VISIBLE   StackModifier = iota // the normal way to play
+	STACK_FADING                         // locked blocks fade away after a while
+	STACK_INVISIBLE                      // locked blocks vanish as soon as they lock
+)
+
+// stack modifiers in the order they're cycled through in the menu
+var stack_modifiers = []StackModifier{STACK_VISIBLE, STACK_FADING, STACK_INVISIBLE}
+
+func (sm StackModifier) String() string {
+	switch sm {
+	case STACK_FADING:
+		return "Fading"
+	case STACK_INVISIBLE:
+		return "Invisible"
+	default:
+		return "Visible"
+	}
+}
+
+// Mode is a game mode, along with the options the player chose for it.
+type Mode struct {
+	Type    GameMode
+	Goal    int    // number of lines to clear, for MODE_SPRINT and MODE_MARATHON. rows of garbage for MODE_DIG
+	Minutes int    // time limit, for MODE_ULTRA
+	Endless bool   // keep playing after the goal is reached, for MODE_MARATHON. keep the garbage coming, for MODE_DIG
+	Messy   bool   // for MODE_DIG
+	Curve   string // name of a preset rise curve or path to a rise curve file, for MODE_SURVIVAL
+	Pack    string // name of the puzzle pack, for MODE_PUZZLE
+	Puzzle  int    // index of the puzzle in its pack, for MODE_PUZZLE
+	Stack   StackModifier
+	Big     bool   // every block takes up a 2x2 area, so the well is half as wide and half as tall
+	Local   bool   // two players share the keyboard and the screen, for MODE_VERSUS
+	AI      bool   // the computer plays: on its own to be watched, or as the right player in a local MODE_VERSUS game
+	Bot     string // name of the external bot the computer plays with. empty for the built-in AI
+}
+
+func (m Mode) Name() string {
+	name := m.baseName()
+	if m.Big {
+		name = "Big " + name
+	}
+
+	if m.Stack != STACK_VISIBLE {
+		name += " " + m.Stack.String()
+	}
+
+	if m.AI && !m.Local {
+		name += " (" + m.botName() + ")"
+	}
+
+	return name
+}
+
+// baseName is the name of the mode, not counting the modifiers.
+func (m Mode) baseName() string {
+	switch m.Type {
+	case MODE_SPRINT:
+		return fmt.Sprintf("Sprint %dL", m.Goal)
+	case MODE_ULTRA:
+		return fmt.Sprintf("Ultra %dmin", m.Minutes)
+	case MODE_MARATHON:
+		if m.Endless {
+			return fmt.Sprintf("Marathon %d+", m.Goal)
+		}
+		return fmt.Sprintf("Marathon %d", m.Goal)
+	case MODE_DIG:
+		return "Dig " + m.OptionName()
+	case MODE_SURVIVAL:
+		return "Survival: " + m.curveName()
+	case MODE_PUZZLE:
+		return fmt.Sprintf("Puzzle %s #%d", m.Pack, m.Puzzle+1)
+	case MODE_VERSUS:
+		if m.Local && m.AI {
+			return "Versus " + m.botName()
+		} else if m.Local {
+			return "Local Versus"
+		}
+		return "Versus"
+	default:
+		return m.Type.String()
+	}
+}
+
+// botName is the name of whoever the computer plays with.
+func (m Mode) botName() string {
+	if m.Bot == "" {
+		return "AI"
+	}
+
+	return m.Bot
+}
+
+// curveName is the name of the mode's rise curve, without any file path.
+func (m Mode) curveName() string {
+	return strings.TrimSuffix(filepath.Base(m.Curve), filepath.Ext(m.Curve))
+}
+
+// OptionName describes the options chosen for the mode, for the menu.
+func (m Mode) OptionName() string {
+	switch m.Type {
+	case MODE_SPRINT:
+		return fmt.Sprintf("%d Lines", m.Goal)
+	case MODE_ULTRA:
+		if m.Minutes == 1 {
+			return "1 Minute"
+		}
+		return fmt.Sprintf("%d Minutes", m.Minutes)
+	case MODE_MARATHON:
+		if m.Endless {
+			return fmt.Sprintf("%d +Endless", m.Goal)
+		}
+		return fmt.Sprintf("%d Lines", m.Goal)
+	case MODE_DIG:
+		if m.Endless {
+			return "Endless"
+		} else if m.Messy {
+			return fmt.Sprintf("%d Messy", m.Goal)
+		}
+		return fmt.Sprintf("%d Tidy", m.Goal)
+	case MODE_PUZZLE:
+		return m.Pack
+	default:
+		return m.Type.String()
+	}
+}
+
+// Leaderboard returns the name of the leaderboard that results in this mode are recorded on. Each sprint length gets
+// its own board, since a 20 line time can't be compared with a 100 line one. Puzzles and versus games aren't ranked,
+// so they return "".
+// Fading and invisible stacks are much harder, so they get boards of their own too, as does big mode.
+func (m Mode) Leaderboard() string {
+	board := m.baseLeaderboard()
+	if board != "" && m.Big {
+		board += "-big"
+	}
+
+	switch {
+	case board == "":
+		return ""
+	case m.Stack == STACK_FADING:
+		return board + "-fading"
+	case m.Stack == STACK_INVISIBLE:
+		return board + "-invisible"
+	default:
+		return board
+	}
+}
+
+func (m Mode) baseLeaderboard() string {
+	switch m.Type {
+	case MODE_SPRINT:
+		return fmt.Sprintf("sprint%d", m.Goal)
+	case MODE_ULTRA:
+		return fmt.Sprintf("ultra%d", m.Minutes)
+	case MODE_MARATHON:
+		// playing past the goal racks up more points, so endless runs get their own board
+		if m.Endless {
+			return fmt.Sprintf("marathon%de", m.Goal)
+		}
+		return fmt.Sprintf("marathon%d", m.Goal)
+	case MODE_DIG:
+		if m.Endless {
+			return "digendless"
+		} else if m.Messy {
+			return fmt.Sprintf("dig%dm", m.Goal)
+		}
+		return fmt.Sprintf("dig%d", m.Goal)
+	case MODE_SURVIVAL:
+		return "survival-" + m.curveName()
+	case MODE_PUZZLE, MODE_VERSUS:
+		return ""
+	case MODE_MASTER:
+		return "master"
+	default:
+		return "endless"
+	}
+}
+
+// RankedByTime reports whether the mode's leaderboard is ordered by fastest time instead of highest score.
+func (m Mode) RankedByTime() bool {
+	return m.Type == MODE_SPRINT || (m.Type == MODE_DIG && !m.Endless)
+}
+
+// apply changes the rules of a game to suit the mode.
+func (m Mode) apply(config *engine.Config) {
+	if m.Big {
+		// the engine plays in a smaller well, and the ui draws every block bigger
+		config.WellSize, config.InvalidLines = bigWell(config.WellSize, config.InvalidLines)
+	}
+
+	switch m.Type {
+	case MODE_SPRINT:
+		config.LineGoal = m.Goal
+		config.LinesPerLevel = 0 // sprints are played at a constant speed, it's the player that has to go fast
+	case MODE_ULTRA:
+		config.TimeLimit = m.Minutes * 60 * 60
+	case MODE_MARATHON:
+		config.LineGoal = m.Goal
+		if config.LinesPerLevel > 0 { // otherwise the level never changes, so there's nothing to cap
+			config.MaxLevel = m.Goal / config.LinesPerLevel
+		}
+		config.PlayPastGoal = m.Endless
+	case MODE_DIG:
+		// leave some room above the garbage, so a short well doesn't top out straight away
+		max_rows := config.WellSize.H - config.InvalidLines - 4
+		if m.Endless {
+			config.Garbage = engine.Garbage{
+				StartRows: min(dig_endless_rows, max_rows),
+				Messiness: dig_endless_messiness,
+				Rise:      engine.FixedRise(dig_endless_interval),
+			}
+		} else {
+			config.Garbage = engine.Garbage{
+				StartRows: min(m.Goal, max_rows),
+				Messiness: dig_tidy_messiness,
+				Goal:      min(m.Goal, max_rows),
+			}
+			if m.Messy {
+				config.Garbage.Messiness = dig_messy_messiness
+			}
+			config.LinesPerLevel = 0
+		}
+	case MODE_SURVIVAL:
+		config.Garbage = engine.Garbage{
+			StartRows: survival_start_rows,
+			Messiness: survival_messiness,
+			Rise:      getRiseCurve(m.Curve),
+		}
+	case MODE_PUZZLE:
+		puzzle, err := loadPuzzle(m.Pack, m.Puzzle, config.WellSize.W)
+		if err != nil {
+			log.Error("Could not load puzzle: ", err)
+			return
+		}
+
+		setup, _ := puzzle.setup(config.WellSize.W) // already checked when the pack was loaded
+		config.Setup = &setup
+		config.Objective = puzzle.Objective
+		config.LinesPerLevel = 0
+	case MODE_MASTER:
+		// the full TGM experience. pieces come from the tgm randomizer, and lock delay only resets when pieces fall
+		config.Levelling = engine.LEVEL_TGM
+		config.MaxLevel = master_max_level
+		config.LevelGoal = master_max_level
+		config.Gravity = engine.MasterGravity
+		config.Delays = engine.MasterDelays
+		config.Scoring = engine.MasterScoring{}
+		config.Randomizer = engine.HistoryRandomizer(6)
+		config.LockDelay = 30
+		config.LockReset = engine.LOCK_RESET_STEP
+	case MODE_VERSUS:
+		config.Attack = engine.GuidelineAttack
+	}
+}
+
+// bigWell scales a well down for big mode. Any rows that don't fit a whole block are left over at the top, and the
+// invalid lines are rounded up so the spawn area isn't any smaller than usual.
+func bigWell(size vec.Dims, invalid_lines int) (vec.Dims, int) {
+	return vec.Dims{size.W / big_scale, size.H / big_scale}, (invalid_lines + big_scale - 1) / big_scale
+}
+
+// formatTime formats a number of ticks as minutes and seconds, with the seconds shown to the provided number of
+// decimal places (up to 3).
+func formatTime(ticks int, decimals int) string {
+	ms := ticks * 1000 / 60
+	text := fmt.Sprintf("%d:%02d", ms/60000, ms/1000%60)
+	if decimals > 0 {
+		text += fmt.Sprintf(".%03d", ms%1000)[:decimals+1]
+	}
+
+	return text
+}
+
+// formatTimeDelta formats the difference between two times in seconds, with a sign so it's obvious which is better.
+func formatTimeDelta(ticks int) string {
+	return fmt.Sprintf("%+.3f", float64(ticks)/60)
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bennicholls/tytris/ai"
+	"github.com/bennicholls/tytris/engine"
+	"github.com/bennicholls/tyumi/gfx"
+	"github.com/bennicholls/tyumi/gfx/col"
+	"github.com/bennicholls/tyumi/gfx/ui"
+	"github.com/bennicholls/tyumi/input"
+	"github.com/bennicholls/tyumi/log"
+	"github.com/bennicholls/tyumi/vec"
+)
+
+// Player is one side of the screen: a game, and all of the ui that shows it. Normally there's just the one, but local
+// versus games have two side by side, so each player keeps its own elements instead of looking them up by label.
+type Player struct {
+	name            string
+	keys            KeyMap
+	bot             *ai.Bot // plays instead of the keyboard, if there is one
+	game            engine.Game
+	mode            Mode
+	grader          MasterGrader    // for MODE_MASTER
+	pending_actions []engine.Action // actions from input, applied to the game on the next update
+	well_size       vec.Dims
+	split           bool // sharing the screen, so animations mustn't hold up the other player
+
+	//ui elements
+	playField     PlayField
+	matrixView    MatrixView
+	current_piece PieceElement
+	ghost_piece   PieceElement
+	upcomingArea  UpcomingPieceView
+	heldArea      GridArea
+	held_piece    PieceElement
+	garbage_meter GarbageMeter
+	callout       Callout
+
+	infoArea         ui.Element
+	score            ScoreText
+	time             ui.Textbox
+	level            ui.Textbox
+	next_level_label ui.Textbox
+	next_level       ui.Textbox
+
+	//animations
+	held_flash gfx.FlashAnimation
+}
+
+// setupUI creates the player's elements and adds them to the window, laid out around a well of the provided size.
+func (p *Player) setupUI(window *ui.Window, well_size vec.Dims, l playerLayout) {
+	p.well_size = well_size
+
+	//initialize the playfield, where the blocks fall and the matrix is drawn.
+	p.playField.Init(well_size, l.playfield, 0)
+	p.playField.EnableBorder()
+
+	p.matrixView.Init(well_size, vec.ZERO_COORD, 2)
+	p.matrixView.board = p.game.Board()
+	p.playField.AddChild(&p.matrixView)
+
+	p.current_piece.Init(vec.Dims{3, 2}, vec.Coord{0, 0}, 2)
+	p.ghost_piece = PieceElement{ghost: true}
+	p.ghost_piece.Init(vec.Dims{3, 2}, vec.Coord{0, 0}, 1)
+	p.playField.AddChildren(&p.current_piece, &p.ghost_piece)
+
+	// warning meter for rising garbage, beside the playfield
+	p.garbage_meter.Init(vec.Dims{1, well_size.H}, l.meter, 0)
+
+	// callouts flash up over the playfield. they aren't part of it so they still fit when the well is narrow.
+	p.callout.Init(l.callout.Dims, l.callout.Coord, 2)
+
+	window.AddChildren(&p.playField, &p.garbage_meter, &p.callout)
+
+	// upcoming pieces view, where the next few pieces are displayed in order from left to right
+	p.upcomingArea.Init(l.upcoming.Dims, l.upcoming.Coord, 0)
+
+	// held piece area, where we show which piece the player is holding (if there is one)
+	p.heldArea.Init(vec.Dims{6, 4}, l.held, 0)
+	p.heldArea.SetupBorder("", "held piece")
+	p.held_flash = gfx.NewFlashAnimation(p.heldArea.DrawableArea(), 1, col.Pair{col.NONE, col.NONE}, 15)
+	p.heldArea.AddAnimation(&p.held_flash)
+
+	p.held_piece = PieceElement{preview: true}
+	p.held_piece.Init(vec.Dims{3, 2}, vec.Coord{1, 1}, 1)
+	p.heldArea.AddChild(&p.held_piece)
+
+	window.AddChildren(&p.upcomingArea, &p.heldArea)
+
+	// info area, where we show the player's score, as well as the timer, current level and progress to the next level
+	w := l.info.W
+	p.infoArea.Init(l.info.Dims, l.info.Coord, 1)
+	p.infoArea.EnableBorder()
+
+	scoreLabel := ui.NewTextbox(vec.Dims{w, 1}, vec.Coord{0, 0}, 1, "S C O R E", true)
+	scoreLabel.SetDefaultColours(col.Pair{text_colour, col.NONE})
+	p.score.Init(vec.Dims{w, 1}, vec.Coord{0, 1}, 1)
+	p.score.SetDefaultColours(col.Pair{text_colour, col.NONE})
+	p.infoArea.AddChildren(scoreLabel, &p.score)
+
+	timeLabel := ui.NewTextbox(vec.Dims{w, 1}, vec.Coord{0, 2}, 1, "T I M E R", true)
+	timeLabel.SetDefaultColours(col.Pair{text_colour, border_colour})
+	p.time.Init(vec.Dims{w, 1}, vec.Coord{0, 3}, 1, "0", true)
+	p.time.SetDefaultColours(col.Pair{text_colour, border_colour})
+	p.infoArea.AddChildren(timeLabel, &p.time)
+
+	levelLabel := ui.NewTextbox(vec.Dims{w, 1}, vec.Coord{0, 4}, 1, "L E V E L", true)
+	levelLabel.SetDefaultColours(col.Pair{text_colour, col.NONE})
+	p.level.Init(vec.Dims{w, 1}, vec.Coord{0, 5}, 1, "1", true)
+	p.level.SetDefaultColours(col.Pair{text_colour, col.NONE})
+	p.infoArea.AddChildren(levelLabel, &p.level)
+
+	p.next_level_label.Init(vec.Dims{w, 1}, vec.Coord{0, 6}, 1, "N E X T  L E V E L", true)
+	p.next_level_label.SetDefaultColours(col.Pair{text_colour, border_colour})
+	p.next_level.Init(vec.Dims{w, 1}, vec.Coord{0, 7}, 1, "0", true)
+	p.next_level.SetDefaultColours(col.Pair{text_colour, border_colour})
+	p.infoArea.AddChildren(&p.next_level_label, &p.next_level)
+
+	window.AddChild(&p.infoArea)
+}
+
+// Show shows the player's side of the screen. The garbage meter and callout are left to the mode and the game.
+func (p *Player) Show() {
+	p.playField.Show()
+	p.upcomingArea.Show()
+	p.heldArea.Show()
+	p.infoArea.Show()
+}
+
+// Hide hides the player's side of the screen.
+func (p *Player) Hide() {
+	p.playField.Hide()
+	p.upcomingArea.Hide()
+	p.heldArea.Hide()
+	p.infoArea.Hide()
+	p.garbage_meter.Hide()
+	p.callout.Hide()
+}
+
+// setMode sets the player's ui up for a mode.
+func (p *Player) setMode(mode Mode, fade_time int) {
+	p.mode = mode
+	p.setGrid(mode.Big)
+	p.matrixView.SetStack(mode.Stack, fade_time)
+
+	if mode.Type == MODE_SURVIVAL || mode.Type == MODE_VERSUS || (mode.Type == MODE_DIG && mode.Endless) {
+		p.garbage_meter.Show()
+	} else {
+		p.garbage_meter.Hide()
+	}
+
+	switch mode.Type {
+	case MODE_SPRINT:
+		p.next_level_label.ChangeText("L I N E S  L E F T")
+	case MODE_DIG:
+		p.next_level_label.ChangeText("G A R B A G E")
+	case MODE_PUZZLE:
+		p.next_level_label.ChangeText("P I E C E S")
+	case MODE_MASTER:
+		p.next_level_label.ChangeText("G R A D E")
+	case MODE_VERSUS:
+		p.next_level_label.ChangeText("I N C O M I N G")
+	default:
+		p.next_level_label.ChangeText("N E X T  L E V E L")
+	}
+}
+
+// setGrid lays the well out over the playfield, with big blocks or normal ones.
+func (p *Player) setGrid(big bool) {
+	grid := WellGrid{}
+	lines := invalid_lines
+	if big {
+		// odd rows left over at the top of the playfield are left empty, so the bottom of the well lines up
+		grid = WellGrid{scale: big_scale, origin: vec.Coord{0, p.well_size.H % big_scale}}
+		_, lines = bigWell(p.well_size, invalid_lines)
+	}
+
+	p.playField.SetGrid(grid, lines)
+	p.matrixView.SetGrid(grid)
+	p.current_piece.SetGrid(grid)
+	p.ghost_piece.SetGrid(grid)
+}
+
+// newGame starts a fresh game with the provided rules.
+func (p *Player) newGame(config engine.Config) {
+	p.cleanupUI() // in case we're coming straight from the last game, like when retrying a puzzle
+	p.game.Init(config)
+	p.updateHeldPiece(p.game.HeldPiece())
+	p.grader.Reset()
+	p.pending_actions = p.pending_actions[:0]
+	p.matrixView.pieces = p.game.Config().Pieces
+	p.matrixView.Updated = true
+}
+
+func (p *Player) addAction(actions ...engine.Action) {
+	p.pending_actions = append(p.pending_actions, actions...)
+}
+
+// releases any inputs that are held down. we won't hear about keys being released while the game isn't listening
+// for input (like when paused), so we let go of everything when that happens.
+func (p *Player) releaseHeldInputs() {
+	p.addAction(engine.ACTION_RELEASE_LEFT, engine.ACTION_RELEASE_RIGHT, engine.ACTION_SOFT_DROP_OFF)
+	if p.bot != nil {
+		p.bot.Reset() // the bot thinks it's still holding whatever it was holding, so it starts the piece over
+	}
+}
+
+// stopBot shuts down the player's bot, if they have one.
+func (p *Player) stopBot() {
+	if p.bot == nil {
+		return
+	}
+
+	if err := p.bot.Close(); err != nil {
+		log.Error("Bot stopped working: ", err)
+	}
+	p.bot = nil
+}
+
+// handleKey turns a keypress into an action, if it's one of the player's keys.
+func (p *Player) handleKey(key_event *input.KeyboardEvent) (event_handled bool) {
+	if p.bot != nil {
+		return
+	}
+
+	if action, ok := p.keys.action(key_event.Key, key_event.PressType); ok {
+		p.addAction(action)
+		event_handled = true
+	}
+
+	return
+}
+
+// step advances the game by one tick and updates the ui to match. Returns everything that happened.
+func (p *Player) step(actions []engine.Action) (events []engine.Event) {
+	events = p.game.Step(actions)
+	for _, e := range events {
+		p.handleGameEvent(e)
+	}
+
+	p.current_piece.SetLockTimer(p.game.LockTimer())
+	p.matrixView.SetTime(p.game.Info().Time)
+	p.updateCountdown()
+	if p.mode.Type == MODE_MASTER {
+		p.grader.Update(p.game.Info())
+	}
+
+	if p.mode.Type == MODE_VERSUS {
+		p.garbage_meter.SetQueued(p.game.IncomingGarbage())
+	} else {
+		p.garbage_meter.SetTimer(p.game.GarbageTimer())
+	}
+
+	return
+}
+
+// updateCountdown warns the player when the time limit is about to run out, ticking and pulsing the timer every
+// second for the last 10 seconds.
+func (p *Player) updateCountdown() {
+	remaining := p.game.TimeRemaining()
+	if remaining == 0 || remaining > countdown_warning_time || remaining%60 != 0 {
+		return
+	}
+
+	pulse := gfx.NewPulseAnimation(p.time.DrawableArea(), 0, 30, col.Pair{col.RED, col.NONE})
+	pulse.OneShot = true
+	pulse.Start()
+	p.time.AddAnimation(&pulse)
+	sounds.Play("tick")
+}
+
+// handleGameEvent reacts to things happening in the game, updating the ui and playing sounds and animations. Ending the
+// game is up to TyTris.
+func (p *Player) handleGameEvent(e engine.Event) {
+	switch e.Type {
+	case engine.EV_PIECE_SPAWNED:
+		p.updatePieceElements()
+		p.upcomingArea.UpdatePieces(p.game.UpcomingPieces(6))
+	case engine.EV_PIECE_MOVED:
+		p.updatePieceElements()
+		if e.Dir != vec.DIR_DOWN {
+			sounds.Play("move")
+		}
+	case engine.EV_PIECE_ROTATED:
+		p.updatePieceElements()
+		sounds.Play("rotate")
+	case engine.EV_PIECE_LOCKED:
+		p.matrixView.Updated = true
+		p.updatePieceElements()
+
+		for _, line := range e.Lines {
+			lda := NewLineDestroyAnimation(vec.Rect{p.matrixView.grid.Cell(vec.Coord{0, line}), vec.Dims{p.playField.Size().W, p.matrixView.grid.Scale()}})
+			lda.Blocking = !p.split
+			p.playField.AddAnimation(&lda)
+		}
+
+		if e.TSpin != engine.TSPIN_NONE {
+			p.calloutTSpin(e.TSpin, len(e.Lines))
+		}
+
+		if len(e.Lines) > 0 {
+			p.matrixView.Reveal(line_clear_reveal_duration)
+			sounds.Play("kill")
+		} else if e.Dropped {
+			sounds.Play("drop")
+		} else {
+			sounds.Play("lock")
+		}
+	case engine.EV_PIECE_HELD:
+		p.updateHeldPiece(e.Piece)
+
+		colour := e.Piece.Colour()
+		p.held_flash.ToColours = col.Pair{colour, colour}
+		p.held_flash.Play()
+		sounds.Play("swap")
+	case engine.EV_LINES_DESTROYED:
+		p.matrixView.Updated = true
+		p.playField.Updated = true
+	case engine.EV_SCORE:
+		p.updateScore(e)
+	case engine.EV_LEVEL_UP:
+		pulse := gfx.NewPulseAnimation(p.level.DrawableArea(), 0, 30, col.Pair{col.GREEN, col.NONE})
+		pulse.OneShot = true
+		pulse.Start()
+		p.level.AddAnimation(&pulse)
+	case engine.EV_GRAVITY_CHANGED:
+		speedup_animation := NewSpeedUpAnimation(p.playField.Size().H)
+		p.playField.AddAnimation(&speedup_animation)
+		sounds.Play("speedup")
+	case engine.EV_GOAL_REACHED:
+		if !p.game.IsOver() { // playing past the goal
+			p.callout.Flash(strings.ToUpper(p.mode.Type.String())+" CLEARED!", col.GREEN, 120)
+			sounds.Play("speedup")
+		}
+	case engine.EV_GARBAGE_RISEN:
+		p.matrixView.Updated = true
+		p.updatePieceElements()
+		sounds.Play("lock")
+	}
+}
+
+// updateHeldPiece shows the piece in the held piece area.
+func (p *Player) updateHeldPiece(piece engine.Piece) {
+	p.heldArea.Updated = true
+
+	// center the piece in the held area. when it can't be centered exactly it leans down and to the left
+	p.held_piece.UpdatePiece(piece)
+	area := p.heldArea.Size()
+	p.held_piece.MoveTo(vec.Coord{(area.W - p.held_piece.Size().W) / 2, (area.H - p.held_piece.Size().H + 1) / 2})
+}
+
+// updatePieceElements syncs the current piece and ghost elements with the game.
+func (p *Player) updatePieceElements() {
+	p.current_piece.UpdatePiece(p.game.CurrentPiece())
+	p.ghost_piece.UpdatePiece(p.game.Ghost())
+}
+
+// flashes a callout in the playfield announcing a t-spin.
+func (p *Player) calloutTSpin(tspin engine.TSpin, lines int) {
+	text := "T-SPIN"
+	if tspin == engine.TSPIN_MINI {
+		text = "MINI " + text
+	}
+
+	switch lines {
+	case 1:
+		text += " SINGLE"
+	case 2:
+		text += " DOUBLE"
+	case 3:
+		text += " TRIPLE"
+	}
+
+	p.callout.Flash(text+"!", engine.StandardPieces.Highlight(engine.T), 60)
+}
+
+func (p *Player) updateScore(e engine.Event) {
+	if e.Dropped {
+		p.score.SetScore(p.game.Info().Score)
+	} else {
+		p.score.AddPoints(p.game.Info().Score, e.Points)
+	}
+}
+
+// updateInfo shows the time, level and progress of the game in the info area.
+func (p *Player) updateInfo() {
+	info := p.game.Info()
+	if p.mode.RankedByTime() {
+		p.time.ChangeText(formatTime(info.Time, 3))
+	} else if p.mode.Type == MODE_ULTRA {
+		p.time.ChangeText(formatTime(p.game.TimeRemaining(), 1))
+	} else {
+		p.time.ChangeText(strconv.Itoa(info.Time / 60))
+	}
+
+	if p.mode.Type == MODE_MASTER {
+		// show the level the player is working towards, like tgm
+		next_stop := min((info.Level/100+1)*100, master_max_level)
+		p.level.ChangeText(fmt.Sprintf("%d / %d", info.Level, next_stop))
+	} else {
+		p.level.ChangeText(strconv.Itoa(info.Level))
+	}
+
+	if p.mode.Type == MODE_SPRINT {
+		p.next_level.ChangeText(strconv.Itoa(p.game.LinesToGoal()) + " lines")
+	} else if p.mode.Type == MODE_DIG {
+		p.next_level.ChangeText(strconv.Itoa(p.game.Board().GarbageLines()) + " lines")
+	} else if p.mode.Type == MODE_PUZZLE {
+		p.next_level.ChangeText(strconv.Itoa(p.game.PiecesLeft()) + " left")
+	} else if p.mode.Type == MODE_MASTER {
+		p.next_level.ChangeText(p.grader.Grade(info))
+	} else if p.mode.Type == MODE_VERSUS {
+		p.next_level.ChangeText(strconv.Itoa(p.game.IncomingGarbage()) + " lines")
+	} else if lines := p.game.LinesToNextLevel(); lines > 0 {
+		p.next_level.ChangeText(strconv.Itoa(lines) + " lines")
+	} else {
+		p.next_level.ChangeText("-")
+	}
+}
+
+func (p *Player) cleanupUI() {
+	p.game.Board().Clear()
+	p.matrixView.Reveal(0)
+
+	p.upcomingArea.Reset()
+
+	p.callout.Hide()
+	p.held_piece.UpdatePiece(engine.Piece{Type: engine.NO_PIECE})
+	p.heldArea.Updated = true
+
+	p.score.SetScore(0)
+	p.time.ChangeText("0")
+	p.level.ChangeText("1")
+	p.next_level.ChangeText("0")
+}
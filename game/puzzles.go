@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bennicholls/tytris/engine"
+	"github.com/bennicholls/tyumi/log"
+)
+
+var puzzle_directory string = "puzzles"
+var puzzle_progress_filename string = "puzzles.progress"
+
+const puzzle_extension string = ".json"
+
+// PuzzlePack is a collection of puzzles, stored together in a json file in the puzzle directory. Packs are known by
+// their filename.
+type PuzzlePack struct {
+	Title   string
+	Puzzles []Puzzle
+}
+
+// Puzzle is a starting position and a fixed queue of pieces, with an objective to complete before the pieces run out.
+type Puzzle struct {
+	Name      string
+	Hint      string   // shown to the player along with the objective
+	Board     []string // rows of the starting matrix from the top down. '.' is empty, piece letters are blocks and anything else is garbage
+	Queue     string   // the pieces the player gets, as piece letters
+	Hold      string   // letter of the piece that starts in the hold, if there is one
+	Objective engine.Objective
+}
+
+// setup converts the puzzle into a starting position for the engine, in a well of the provided width.
+func (p Puzzle) setup(well_width int) (setup engine.Setup, err error) {
+	setup.Board = p.Board
+	for i, row := range p.Board {
+		blocks, err := engine.ParseBoardRow(row)
+		if err != nil {
+			return setup, err
+		}
+
+		if len(blocks) > well_width {
+			return setup, fmt.Errorf("row %d is %d wide, but the well is only %d wide", i+1, len(blocks), well_width)
+		}
+	}
+
+	setup.Queue, err = engine.ParseSequence(p.Queue)
+	if err != nil {
+		return
+	}
+
+	setup.Held = engine.NO_PIECE
+	if p.Hold != "" {
+		held, err := engine.ParseSequence(p.Hold)
+		if err != nil || len(held) != 1 {
+			return setup, errors.New("hold must be a single piece letter")
+		}
+		setup.Held = held[0]
+	}
+
+	if p.Objective.IsEmpty() {
+		err = errors.New("puzzle has no objective")
+	}
+
+	return
+}
+
+// ObjectiveText describes what the player has to do to solve the puzzle.
+func (p Puzzle) ObjectiveText() string {
+	var goals []string
+	if p.Objective.Lines > 0 {
+		if p.Objective.Lines == 1 {
+			goals = append(goals, "clear 1 line")
+		} else {
+			goals = append(goals, fmt.Sprintf("clear %d lines", p.Objective.Lines))
+		}
+	}
+	if p.Objective.TSpinDoubles == 1 {
+		goals = append(goals, "perform a t-spin double")
+	} else if p.Objective.TSpinDoubles > 1 {
+		goals = append(goals, fmt.Sprintf("perform %d t-spin doubles", p.Objective.TSpinDoubles))
+	}
+	if p.Objective.PerfectClear {
+		goals = append(goals, "get a perfect clear")
+	}
+
+	text := strings.Join(goals, " and ")
+	if p.Objective.MaxPieces > 0 {
+		text += fmt.Sprintf(" within %d pieces", p.Objective.MaxPieces)
+	}
+
+	return strings.ToUpper(text[:1]) + text[1:] + "."
+}
+
+// listPuzzlePacks returns the names of the puzzle packs in the puzzle directory, in alphabetical order.
+func listPuzzlePacks() (names []string) {
+	files, err := os.ReadDir(puzzle_directory)
+	if err != nil {
+		log.Info("Could not open puzzle directory: ", err)
+		return
+	}
+
+	for _, file := range files {
+		if name, ok := strings.CutSuffix(file.Name(), puzzle_extension); ok && !file.IsDir() {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+
+	return
+}
+
+// puzzlePackFile returns the path of the puzzle pack with the provided name.
+func puzzlePackFile(name string) string {
+	return filepath.Join(puzzle_directory, name+puzzle_extension)
+}
+
+// loadPuzzlePack reads a puzzle pack from the puzzle directory, checking that all of its puzzles can be played in a
+// well of the provided width.
+func loadPuzzlePack(name string, well_width int) (pack PuzzlePack, err error) {
+	data, err := os.ReadFile(puzzlePackFile(name))
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &pack)
+	if err != nil {
+		return
+	}
+
+	if len(pack.Puzzles) == 0 {
+		return pack, errors.New("pack has no puzzles")
+	}
+
+	for i, puzzle := range pack.Puzzles {
+		if puzzle.Name == "" {
+			pack.Puzzles[i].Name = "Puzzle " + strconv.Itoa(i+1)
+		}
+
+		if _, err = puzzle.setup(well_width); err != nil {
+			return pack, fmt.Errorf("puzzle %d: %w", i+1, err)
+		}
+	}
+
+	if pack.Title == "" {
+		pack.Title = name
+	}
+
+	return
+}
+
+// loadPuzzle finds a single puzzle from a puzzle pack.
+func loadPuzzle(pack_name string, index int, well_width int) (puzzle Puzzle, err error) {
+	pack, err := loadPuzzlePack(pack_name, well_width)
+	if err != nil {
+		return
+	}
+
+	if index < 0 || index >= len(pack.Puzzles) {
+		return puzzle, errors.New("no puzzle " + strconv.Itoa(index+1) + " in pack " + pack_name)
+	}
+
+	return pack.Puzzles[index], nil
+}
+
+// PuzzleProgress records which puzzles the player has solved. Puzzles are listed by name under the name of their pack.
+type PuzzleProgress map[string][]string
+
+func (pp PuzzleProgress) IsSolved(pack, puzzle string) bool {
+	return slices.Contains(pp[pack], puzzle)
+}
+
+func loadPuzzleProgress() (progress PuzzleProgress) {
+	progress = make(PuzzleProgress)
+
+	data, err := os.ReadFile(puzzle_progress_filename)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &progress)
+	if err != nil {
+		log.Warning("Could not decode puzzle progress file: ", err)
+	}
+
+	return
+}
+
+// markPuzzleSolved records a solved puzzle in the progress file.
+func markPuzzleSolved(pack, puzzle string) {
+	progress := loadPuzzleProgress()
+	if progress.IsSolved(pack, puzzle) {
+		return
+	}
+	progress[pack] = append(progress[pack], puzzle)
+
+	data, err := json.MarshalIndent(progress, "", "\t")
+	if err != nil {
+		log.Error("Could not encode puzzle progress: ", err)
+		return
+	}
+
+	err = os.WriteFile(puzzle_progress_filename, data, 0644)
+	if err != nil {
+		log.Error("Could not write puzzle progress file: ", err)
+		return
+	}
+
+	log.Info("Wrote puzzle progress to file ", puzzle_progress_filename)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bennicholls/tytris/engine"
+	"github.com/bennicholls/tyumi"
+	"github.com/bennicholls/tyumi/gfx/col"
+	"github.com/bennicholls/tyumi/log"
+	"github.com/bennicholls/tyumi/util"
+)
+
+var replay_directory string = "replays"
+
+const replay_extension string = ".tyr"
+
+// replays are named after the time they were recorded, so sorting them by name sorts them by date too
+const replay_name_format string = "20060102-150405"
+
+// replayMeta is stored alongside the recorded actions, so the game can be set up the same way when it's played back.
+type replayMeta struct {
+	Settings Settings
+	Mode     Mode
+	Score    int
+	Files    map[string]string // hashes of the files the rules were loaded from, by path. see hashFiles()
+}
+
+// hashFiles hashes the contents of each file, so a replay can tell if the rules it was recorded with have changed.
+// Files that can't be read get an empty hash.
+func hashFiles(files []string) map[string]string {
+	hashes := make(map[string]string)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			hashes[file] = ""
+			continue
+		}
+
+		sum := sha256.Sum256(data)
+		hashes[file] = hex.EncodeToString(sum[:])
+	}
+
+	return hashes
+}
+
+// saveReplay writes the finished game's replay to the replays directory.
+func (t *TyTris) saveReplay() {
+	replay := t.solo.game.Replay()
+
+	meta, err := json.Marshal(replayMeta{
+		Settings: t.settings,
+		Mode:     t.mode,
+		Score:    t.solo.game.Info().Score,
+		Files:    hashFiles(t.settings.ruleFiles(t.mode)),
+	})
+	if err != nil {
+		log.Error("Could not encode replay info: ", err)
+		return
+	}
+	replay.Meta = meta
+
+	err = os.MkdirAll(replay_directory, 0755)
+	if err != nil {
+		log.Error("Could not create replay directory: ", err)
+		return
+	}
+
+	filename := filepath.Join(replay_directory, time.Now().Format(replay_name_format)+replay_extension)
+	err = engine.SaveReplay(filename, replay)
+	if err != nil {
+		log.Error("Could not write replay: ", err)
+		return
+	}
+
+	log.Info("Wrote replay to file ", filename)
+}
+
+// listReplays returns the names of the saved replays, newest first.
+func listReplays() (names []string) {
+	files, err := os.ReadDir(replay_directory)
+	if err != nil {
+		return
+	}
+
+	for _, file := range files {
+		if name, ok := strings.CutSuffix(file.Name(), replay_extension); ok && !file.IsDir() {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+	slices.Reverse(names)
+
+	return
+}
+
+func loadReplay(name string) (replay engine.Replay, meta replayMeta, err error) {
+	replay, err = engine.LoadReplay(filepath.Join(replay_directory, name+replay_extension))
+	if err != nil {
+		return
+	}
+
+	meta.Settings = DefaultSettings()
+	err = json.Unmarshal(replay.Meta, &meta)
+	if err != nil {
+		return
+	}
+
+	// the replay would play back differently if any of the rules it was recorded with have changed since
+	for file, hash := range hashFiles(meta.Settings.ruleFiles(meta.Mode)) {
+		if meta.Files[file] != hash {
+			err = errors.New(file + " has changed since the replay was recorded")
+			return
+		}
+	}
+
+	return
+}
+
+// replayTitle makes a replay's name a little more readable for the replay menu.
+func replayTitle(name string) string {
+	date, err := time.Parse(replay_name_format, name)
+	if err != nil {
+		return name
+	}
+
+	return date.Format("Jan 02 15:04")
+}
+
+// playback speeds, in half-ticks per update. 0.5x up to 8x.
+var replay_speeds = []int{1, 2, 4, 8, 16}
+
+const replay_normal_speed int = 1
+
+func (t *TyTris) startReplay(name string) {
+	replay, meta, err := loadReplay(name)
+	if err != nil {
+		log.Error("Could not load replay ", name, ": ", err)
+		return
+	}
+
+	// the ui is laid out around the well, so we can only show replays that fit it
+	if meta.Settings.WellSize != t.settings.WellSize {
+		log.Error("Could not play replay ", name, ": it was recorded with a different well size.")
+		return
+	}
+
+	settings := meta.Settings
+	settings.Seed = replay.Seed
+	t.setMode(meta.Mode)
+	t.solo.newGame(settings.gameConfig(meta.Mode))
+	t.replay_player = engine.NewReplayPlayer(replay)
+	t.replay_speed = replay_normal_speed
+	t.replay_paused = false
+	t.replay_clock = 0
+
+	fireStateChangeEvent(REPLAYING)
+}
+
+func (t *TyTris) updateReplay() {
+	if t.replay_paused {
+		return
+	}
+
+	t.replay_clock += replay_speeds[t.replay_speed]
+	for ; t.replay_clock >= 2; t.replay_clock -= 2 {
+		if !t.stepReplay() {
+			t.replay_clock = 0
+			break
+		}
+	}
+}
+
+// stepReplay plays back a single tick of the replay. Returns false if playback needs to wait before the next tick,
+// either because the replay is over or because lines were cleared and the animation should play before the stack
+// comes down.
+func (t *TyTris) stepReplay() bool {
+	tick := t.solo.game.Info().Time
+	if t.solo.game.IsOver() || t.replay_player.Done(tick) {
+		fireStateChangeEvent(GAME_OVER)
+		return false
+	}
+
+	actions := t.replay_player.Actions(tick)
+	if slices.Contains(actions, engine.ACTION_PAUSE) {
+		t.solo.callout.Flash("PAUSED", col.GREEN, 60)
+	}
+
+	return !t.step(&t.solo, actions)
+}
+
+func (t *TyTris) changeReplaySpeed(delta int) {
+	t.replay_speed = util.Clamp(t.replay_speed+delta, 0, len(replay_speeds)-1)
+	t.replay_clock = 0
+
+	speed := strconv.FormatFloat(float64(replay_speeds[t.replay_speed])/2, 'g', -1, 64)
+	t.solo.callout.Flash("SPEED "+speed+"x", text_colour, 60)
+	sounds.Play("move")
+}
+
+func (t *TyTris) toggleReplayPause() {
+	t.replay_paused = !t.replay_paused
+
+	if t.replay_paused {
+		t.solo.callout.Pin("PAUSED")
+		tyumi.PauseMusic()
+	} else {
+		t.solo.callout.Hide()
+		tyumi.ResumeMusic()
+	}
+}
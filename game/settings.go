@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/bennicholls/tytris/ai"
+	"github.com/bennicholls/tytris/engine"
+	"github.com/bennicholls/tyumi/log"
+	"github.com/bennicholls/tyumi/util"
+	"github.com/bennicholls/tyumi/vec"
+)
+
+var settings_filename string = "settings.json"
+
+// piece sets that aren't presets are looked for in here, if they aren't given as a path
+var piece_set_directory string = "pieces"
+
+const piece_set_extension string = ".json"
+
+// limits for the size of the well
+var well_size_minimum vec.Dims = vec.Dims{4, 10}
+var well_size_maximum vec.Dims = vec.Dims{20, 40}
+
+// Settings are the player's preferences. They're stored as json so they can be edited by hand.
+type Settings struct {
+	WellSize       vec.Dims
+	Handling       engine.Handling
+	LockDelay      int               // ticks a piece can rest on the stack before it locks. 0 locks it on the next gravity tick
+	LockReset      string            // name of one of the lock reset modes: move, infinite or step
+	MaxLockResets  int               // most times moving a resting piece restarts its lock delay, for the move reset mode
+	GravityCurve   string            // name of one of the preset gravity curves, or the path to a gravity curve file
+	Scoring        string            // name of one of the preset scoring systems, or the path to a scoring table file
+	RotationSystem string            // name of one of the preset rotation systems: srs, ars or simple
+	Randomizer     string            // name of one of the preset randomizers, or the path to a file with a fixed piece sequence
+	Seed           int64             // seed for piece generation. 0 uses a different random seed every game
+	RiseCurve      string            // name of one of the preset rise curves or the path to a rise curve file, for survival mode
+	FadeTime       int               // ticks locked blocks stay visible for when playing with a fading stack
+	MarathonGoal   int               // line goal for marathons, offered in the menu along with the usual goals. 0 for none
+	PieceSet       string            // name of a preset piece set, a piece set in the piece set directory, or the path to a piece set file
+	PlayerName     string            // shown to the opponent in versus games
+	AIDifficulty   string            // name of one of the preset difficulties for the computer player
+	Bots           map[string]string // external bots that speak the Tetris Bot Protocol, by name. each is the command that launches it
+}
+
+func DefaultSettings() Settings {
+	return Settings{
+		WellSize:       vec.Dims{10, 25},
+		Handling:       engine.DefaultHandling(),
+		LockDelay:      engine.DefaultConfig().LockDelay,
+		LockReset:      "move",
+		MaxLockResets:  engine.DefaultConfig().MaxLockResets,
+		GravityCurve:   "guideline",
+		Scoring:        "guideline",
+		RotationSystem: "srs",
+		Randomizer:     "7bag",
+		RiseCurve:      "standard",
+		FadeTime:       5 * 60,
+		PieceSet:       "standard",
+		PlayerName:     "Player",
+		AIDifficulty:   "medium",
+		Bots:           map[string]string{"Stub": "./tbpstub"},
+	}
+}
+
+// gameConfig builds the rules for a game played in the provided mode with these settings.
+func (s Settings) gameConfig(mode Mode) engine.Config {
+	config := engine.DefaultConfig()
+	config.WellSize = s.WellSize
+	config.InvalidLines = invalid_lines
+	config.Handling = s.Handling
+	config.LockDelay = s.LockDelay
+	config.LockReset = s.getLockReset()
+	config.MaxLockResets = s.MaxLockResets
+	config.Gravity = s.getGravityCurve()
+	config.Scoring = s.getScoring()
+	config.RotationSystem = s.getRotationSystem()
+	config.Randomizer = s.getRandomizer()
+	config.Pieces = s.getPieceSet()
+	config.Seed = s.Seed
+	mode.apply(&config)
+	return config
+}
+
+// getRandomizer finds the randomizer named in the settings, loading a fixed sequence from a file if it isn't a preset.
+// Falls back to the 7-bag if there's a problem.
+func (s Settings) getRandomizer() engine.RandomizerMaker {
+	if randomizer, ok := engine.Randomizers[s.Randomizer]; ok {
+		return randomizer
+	}
+
+	sequence, err := engine.LoadSequence(s.Randomizer)
+	if err != nil {
+		log.Error("Could not load piece sequence ", s.Randomizer, ": ", err)
+		return engine.BagRandomizer(1)
+	}
+
+	return engine.SequenceRandomizer(sequence)
+}
+
+// getLockReset finds the lock reset mode named in the settings. Falls back to resetting on moves if there's no mode
+// with that name.
+func (s Settings) getLockReset() engine.LockResetMode {
+	if mode, ok := engine.LockResetModes[s.LockReset]; ok {
+		return mode
+	}
+
+	log.Error("No lock reset mode called ", s.LockReset, ", using move.")
+	return engine.LOCK_RESET_MOVE
+}
+
+// getDifficulty finds the computer player's difficulty named in the settings. Falls back to medium if there's no preset
+// with that name.
+func (s Settings) getDifficulty() ai.Difficulty {
+	if difficulty, ok := ai.Difficulties[s.AIDifficulty]; ok {
+		return difficulty
+	}
+
+	log.Error("No AI difficulty called ", s.AIDifficulty, ", using medium.")
+	return ai.Difficulties["medium"]
+}
+
+// newBot makes a computer player, held back by the difficulty in the settings. If the name is one of the external bots
+// it's launched to do the thinking, otherwise (or if it can't be) the built-in AI does.
+func (s Settings) newBot(name string, seed int64) *ai.Bot {
+	difficulty := s.getDifficulty()
+	var brain ai.Brain = ai.NewHeuristic(difficulty.Mistakes, seed)
+	if name == "" {
+		return ai.NewBot(brain, difficulty.PPS)
+	}
+
+	command, ok := s.Bots[name]
+	if !ok {
+		log.Error("No bot called ", name, ", using the built-in AI.")
+		return ai.NewBot(brain, difficulty.PPS)
+	}
+
+	external, err := ai.NewExternal(command, brain)
+	if err != nil {
+		log.Error("Could not launch bot ", name, ", using the built-in AI: ", err)
+		return ai.NewBot(brain, difficulty.PPS)
+	}
+
+	log.Info("Launched bot ", name, ".")
+	return ai.NewBot(external, difficulty.PPS)
+}
+
+// botNames lists the external bots in the settings, in alphabetical order.
+func (s Settings) botNames() []string {
+	return slices.Sorted(maps.Keys(s.Bots))
+}
+
+// getPieceSet finds the piece set named in the settings, loading it from a file if it isn't a preset. Falls back to
+// the standard pieces if there's a problem.
+func (s Settings) getPieceSet() engine.PieceSet {
+	if set, ok := engine.PieceSets[s.PieceSet]; ok {
+		return set
+	}
+
+	set, err := engine.LoadPieceSet(pieceSetFile(s.PieceSet))
+	if err != nil {
+		log.Error("Could not load piece set ", s.PieceSet, ": ", err)
+		return engine.StandardPieces
+	}
+
+	log.Info("Loaded piece set ", set.Name, " with ", len(set.Pieces), " pieces.")
+	return set
+}
+
+// pieceSetFile returns the path of the piece set file with the provided name. Names without an extension are looked for
+// in the piece set directory.
+func pieceSetFile(name string) string {
+	if filepath.Ext(name) == "" {
+		return filepath.Join(piece_set_directory, name+piece_set_extension)
+	}
+
+	return name
+}
+
+// ruleFiles lists the files the rules for a game in the provided mode are loaded from, for the settings and the mode
+// that aren't presets.
+func (s Settings) ruleFiles(mode Mode) (files []string) {
+	// master mode brings its own gravity, scoring and randomizer
+	if mode.Type != MODE_MASTER {
+		if _, ok := engine.Randomizers[s.Randomizer]; !ok {
+			files = append(files, s.Randomizer)
+		}
+		if _, ok := engine.GravityCurves[s.GravityCurve]; !ok {
+			files = append(files, s.GravityCurve)
+		}
+		if _, ok := engine.ScoringSystems[s.Scoring]; !ok {
+			files = append(files, s.Scoring)
+		}
+	}
+
+	if _, ok := engine.PieceSets[s.PieceSet]; !ok {
+		files = append(files, pieceSetFile(s.PieceSet))
+	}
+
+	switch mode.Type {
+	case MODE_SURVIVAL:
+		if _, ok := engine.RiseCurves[mode.Curve]; !ok {
+			files = append(files, mode.Curve)
+		}
+	case MODE_PUZZLE:
+		files = append(files, puzzlePackFile(mode.Pack))
+	}
+
+	return
+}
+
+// getRotationSystem finds the rotation system named in the settings. Falls back to SRS if there's no preset with that
+// name.
+func (s Settings) getRotationSystem() engine.RotationSystem {
+	if system, ok := engine.RotationSystems[s.RotationSystem]; ok {
+		return system
+	}
+
+	log.Error("No rotation system called ", s.RotationSystem, ", using srs.")
+	return engine.SRS{}
+}
+
+// getScoring finds the scoring system named in the settings, loading a scoring table from a file if it isn't a preset.
+// Falls back to guideline scoring if there's a problem.
+func (s Settings) getScoring() engine.ScoringSystem {
+	if scoring, ok := engine.ScoringSystems[s.Scoring]; ok {
+		return scoring
+	}
+
+	scoring, err := engine.LoadScoring(s.Scoring)
+	if err != nil {
+		log.Error("Could not load scoring table ", s.Scoring, ": ", err)
+		return engine.GuidelineScoring
+	}
+
+	return scoring
+}
+
+// getGravityCurve finds the gravity curve named in the settings, loading it from a file if it isn't a preset. Falls
+// back to the guideline curve if there's a problem.
+func (s Settings) getGravityCurve() engine.GravityCurve {
+	if curve, ok := engine.GravityCurves[s.GravityCurve]; ok {
+		return curve
+	}
+
+	curve, err := engine.LoadGravityCurve(s.GravityCurve)
+	if err != nil {
+		log.Error("Could not load gravity curve ", s.GravityCurve, ": ", err)
+		return engine.GuidelineGravity
+	}
+
+	return curve
+}
+
+// getRiseCurve finds a rise curve by name, loading it from a file if it isn't a preset. Falls back to the standard
+// curve if there's a problem.
+func getRiseCurve(name string) engine.RiseCurve {
+	if curve, ok := engine.RiseCurves[name]; ok {
+		return curve
+	}
+
+	curve, err := engine.LoadRiseCurve(name)
+	if err != nil {
+		log.Error("Could not load rise curve ", name, ": ", err)
+		return engine.RiseCurves["standard"]
+	}
+
+	return curve
+}
+
+func (s *Settings) WriteToDisk() {
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		log.Error("Could not encode settings: ", err)
+		return
+	}
+
+	err = os.WriteFile(settings_filename, data, 0644)
+	if err != nil {
+		log.Error("Could not write settings file: ", err)
+		return
+	}
+
+	log.Info("Wrote settings to file ", settings_filename)
+}
+
+func (s *Settings) LoadFromDisk() {
+	*s = DefaultSettings()
+
+	data, err := os.ReadFile(settings_filename)
+	if err != nil {
+		log.Info("Could not open settings file, using defaults.")
+		return
+	}
+
+	err = json.Unmarshal(data, s)
+	if err != nil {
+		log.Warning("Could not decode settings file, using defaults. Error: ", err)
+		*s = DefaultSettings()
+	}
+
+	s.WellSize.W = util.Clamp(s.WellSize.W, well_size_minimum.W, well_size_maximum.W)
+	s.WellSize.H = util.Clamp(s.WellSize.H, well_size_minimum.H, well_size_maximum.H)
+	s.LockDelay = max(s.LockDelay, 0)
+	s.MaxLockResets = max(s.MaxLockResets, 0)
+	s.MarathonGoal = max(s.MarathonGoal, 0)
+}
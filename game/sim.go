@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bennicholls/tytris/ai"
+	"github.com/bennicholls/tytris/engine"
+	"github.com/bennicholls/tyumi/log"
+)
+
+// how long a simulated game waits for the bot to decide on a piece before letting the game carry on without it
+const sim_think_timeout = time.Second
+
+// SimResult is the stats from one simulated game.
+type SimResult struct {
+	Game    int // index of the game in the batch
+	Seed    int64
+	Score   int
+	Lines   int
+	Pieces  int
+	Seconds float64 // time played, in game time
+	PPS     float64 // pieces placed per second of game time
+	Level   int
+	Ending  string // how the game ended. see simEnding
+	Error   string `json:",omitempty"` // whatever went wrong with the bot, if anything did
+}
+
+// runSim plays a batch of games with the computer in control and writes out the stats from each, without opening a
+// window or playing any sound. It's what `tytris sim` does, for testing rule changes and bots at scale. args are the
+// command line arguments after "sim". Returns the exit code.
+func runSim(args []string) int {
+	flags := flag.NewFlagSet("tytris sim", flag.ContinueOnError)
+	mode_name := flags.String("mode", "endless", "mode to play: endless, sprint, ultra, marathon, dig, survival or master")
+	goal := flags.Int("goal", 0, "line goal for sprint and marathon, or rows of garbage for dig. 0 uses the first option from the menu")
+	minutes := flags.Int("minutes", 0, "time limit for ultra. 0 uses the first option from the menu")
+	endless := flags.Bool("endless", false, "play past the goal in marathon, or keep the garbage coming in dig")
+	messy := flags.Bool("messy", false, "use messy garbage in dig")
+	games := flags.Int("games", 100, "number of games to play")
+	seed := flags.Int64("seed", 1, "seed for the first game. each game after uses the next seed")
+	randomizer := flags.String("randomizer", "", "randomizer to use, instead of the one in the settings")
+	bot := flags.String("bot", "", "external bot from the settings to play with. the built-in AI plays if empty")
+	difficulty := flags.String("difficulty", "max", "difficulty of the computer player")
+	limit := flags.Int("limit", 30, "minutes of game time to stop a game after, if it hasn't ended. 0 for no limit")
+	parallel := flags.Int("parallel", runtime.NumCPU(), "number of games to play at once")
+	format := flags.String("format", "csv", "output format: csv or json")
+	out := flags.String("out", "", "file to write the stats to. stats are written to stdout if empty")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// there's no window to show the log in, so warnings and errors go to stderr
+	log.SetMinimumLogLevel(log.LVL_WARN)
+	log.SetOnMessageCallback(func(e log.Entry) {
+		fmt.Fprintln(os.Stderr, e)
+	})
+
+	settings := Settings{}
+	settings.LoadFromDisk()
+	settings.AIDifficulty = *difficulty
+	if *randomizer != "" {
+		settings.Randomizer = *randomizer
+	}
+
+	mode, err := simMode(*mode_name, *goal, *minutes, *endless, *messy)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	mode.Bot = *bot
+	if mode.Type == MODE_SURVIVAL {
+		mode.Curve = settings.RiseCurve
+	}
+
+	if _, ok := ai.Difficulties[*difficulty]; !ok {
+		fmt.Fprintln(os.Stderr, "No AI difficulty called", *difficulty)
+		return 2
+	}
+
+	if _, ok := settings.Bots[*bot]; *bot != "" && !ok {
+		fmt.Fprintln(os.Stderr, "No bot called", *bot, "in the settings")
+		return 2
+	}
+
+	if *format != "csv" && *format != "json" {
+		fmt.Fprintln(os.Stderr, "Unknown output format", *format)
+		return 2
+	}
+
+	// the config is built once up front, so any files the rules come from are only loaded (and complained about) once
+	config := settings.gameConfig(mode)
+
+	results := make([]SimResult, max(*games, 0))
+	started := time.Now()
+
+	var launching sync.Mutex // the log isn't safe to use from several goroutines, and launching bots can log
+	var wait sync.WaitGroup
+	queue := make(chan int)
+	for range max(*parallel, 1) {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for i := range queue {
+				game_config := config
+				game_config.Seed = *seed + int64(i)
+
+				launching.Lock()
+				bot := settings.newBot(mode.Bot, game_config.Seed)
+				launching.Unlock()
+
+				results[i] = simulate(game_config, bot, *limit*60*60)
+				results[i].Game = i
+			}
+		}()
+	}
+
+	for i := range results {
+		queue <- i
+	}
+	close(queue)
+	wait.Wait()
+
+	output := io.Writer(os.Stdout)
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Could not create output file:", err)
+			return 1
+		}
+		defer file.Close()
+		output = file
+	}
+
+	if *format == "json" {
+		err = writeSimJSON(output, results)
+	} else {
+		err = writeSimCSV(output, results)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Could not write stats:", err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "Played %d games of %s in %s.\n", len(results), mode.Name(), time.Since(started).Round(time.Millisecond))
+	return 0
+}
+
+// simMode finds the mode to simulate by name, and applies the chosen options to it. Options that aren't chosen come
+// from the first variation of the mode in the menu. Puzzles and versus games can't be simulated.
+func simMode(name string, goal, minutes int, endless, messy bool) (mode Mode, err error) {
+	i := slices.IndexFunc(game_modes, func(gm GameMode) bool {
+		return gm != MODE_PUZZLE && strings.EqualFold(gm.String(), name)
+	})
+	if i == -1 {
+		return mode, errors.New("can't simulate a mode called " + name)
+	}
+
+	mode = game_modes[i].Options(0)[0]
+	if goal > 0 {
+		mode.Goal = goal
+	}
+	if minutes > 0 {
+		mode.Minutes = minutes
+	}
+	mode.Endless = mode.Endless || endless
+	mode.Messy = messy
+	mode.AI = true
+
+	return
+}
+
+// simulate plays a game with the bot, as fast as it can. limit is the most ticks the game can go on for, 0 for no
+// limit.
+func simulate(config engine.Config, bot *ai.Bot, limit int) (result SimResult) {
+	var game engine.Game
+	game.Init(config)
+
+	var events []engine.Event
+	for !game.IsOver() && (limit == 0 || game.Info().Time < limit) {
+		actions := bot.Actions(&game)
+		for deadline := time.Now().Add(sim_think_timeout); bot.Thinking() && time.Now().Before(deadline); {
+			time.Sleep(time.Millisecond)
+			actions = bot.Actions(&game)
+		}
+		events = game.Step(actions)
+	}
+
+	if err := bot.Close(); err != nil {
+		result.Error = err.Error()
+	}
+
+	info := game.Info()
+	result.Seed = info.Seed
+	result.Score = info.Score
+	result.Lines = info.LinesDestroyed
+	result.Pieces = info.PiecesDropped
+	result.Seconds = float64(info.Time) / 60
+	if result.Seconds > 0 {
+		result.PPS = float64(info.PiecesDropped) / result.Seconds
+	}
+	result.Level = info.Level
+	result.Ending = simEnding(&game, events)
+
+	return
+}
+
+// simEnding describes how a simulated game ended. A top out is "garbage" if rising garbage pushed the stack out of the
+// well, and "stack" if the bot stacked it there. Games stopped at the time limit are "unfinished".
+func simEnding(game *engine.Game, last_events []engine.Event) string {
+	switch game.Ending() {
+	case engine.ENDING_TOP_OUT:
+		if slices.ContainsFunc(last_events, func(e engine.Event) bool { return e.Type == engine.EV_GARBAGE_RISEN }) {
+			return "garbage"
+		}
+		return "stack"
+	case engine.ENDING_GOAL:
+		return "goal"
+	case engine.ENDING_TIME_UP:
+		return "time up"
+	case engine.ENDING_OUT_OF_PIECES:
+		return "out of pieces"
+	default:
+		return "unfinished"
+	}
+}
+
+func writeSimCSV(w io.Writer, results []SimResult) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"game", "seed", "score", "lines", "pieces", "seconds", "pps", "level", "ending", "error"})
+	for _, r := range results {
+		out.Write([]string{
+			strconv.Itoa(r.Game),
+			strconv.FormatInt(r.Seed, 10),
+			strconv.Itoa(r.Score),
+			strconv.Itoa(r.Lines),
+			strconv.Itoa(r.Pieces),
+			strconv.FormatFloat(r.Seconds, 'f', 2, 64),
+			strconv.FormatFloat(r.PPS, 'f', 3, 64),
+			strconv.Itoa(r.Level),
+			r.Ending,
+			r.Error,
+		})
+	}
+	out.Flush()
+
+	return out.Error()
+}
+
+func writeSimJSON(w io.Writer, results []SimResult) error {
+	out := json.NewEncoder(w)
+	out.SetIndent("", "\t")
+	return out.Encode(results)
+}
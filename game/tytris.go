@@ -2,50 +2,60 @@ package main
 
 import (
 	"math/rand"
-	"slices"
-	"strconv"
+	"os"
 
+	"github.com/bennicholls/tytris/engine"
+	"github.com/bennicholls/tytris/versus"
 	"github.com/bennicholls/tyumi"
 	"github.com/bennicholls/tyumi/gfx"
 	"github.com/bennicholls/tyumi/gfx/col"
 	"github.com/bennicholls/tyumi/gfx/ui"
 	"github.com/bennicholls/tyumi/log"
 	"github.com/bennicholls/tyumi/platform/sdl"
-	"github.com/bennicholls/tyumi/util"
 	"github.com/bennicholls/tyumi/vec"
 	//"github.com/pkg/profile"
 )
 
-var well_size vec.Dims = vec.Dims{10, 25}
-var starting_gravity int = 45
-var speed_up_gravity int = 8    //gravity for when the player is holding DOWN
-var acceleration_time int = 300 //speed up every 300 ticks (5 seconds)
-var gravity_minimum int = 5
 var invalid_lines int = 3
 
 var sounds tyumi.SoundLibrary
 var menuMusic tyumi.AudioResource
 var playingMusic tyumi.AudioResource
 var gameOverMusic tyumi.AudioResource
+var victoryMusic tyumi.AudioResource
 
 var debug bool
 
+// ticks before the time limit runs out that the countdown starts warning the player
+const countdown_warning_time int = 10 * 60
+
 func main() {
 	//defer profile.Start(profile.CPUProfile, profile.ProfilePath(".")).Stop()
-	tyumi.InitConsole(vec.Dims{48, 27})
+
+	// tytris sim plays games headlessly, so it has to go before any of the window and audio setup
+	if len(os.Args) > 1 && os.Args[1] == "sim" {
+		os.Exit(runSim(os.Args[2:]))
+	}
+
+	//settings need to be loaded first, since the size of the well decides how big the console is
+	settings := Settings{}
+	settings.LoadFromDisk()
+
+	tyumi.InitConsole(computeLayout(settings.WellSize).console)
 	tyumi.SetPlatform(sdl.New())
 	tyumi.SetupRenderer("res/tytris-glyphs24x24.bmp", "res/font12x24.bmp", "TyTris")
 	tyumi.EnableAudio()
-	
+
 	ui.SetDefaultElementVisuals(gfx.Visuals{
 		Mode:    gfx.DRAW_GLYPH,
 		Colours: col.Pair{border_colour, background_colour},
 	})
 
-	game := TyTris{}
+	game := TyTris{settings: settings}
 	game.Init(vec.Dims{tyumi.FIT_CONSOLE, tyumi.FIT_CONSOLE})
 	game.setup()
 	defer game.highScores.WriteToDisk()
+	defer game.settings.WriteToDisk()
 
 	tyumi.SetInitialMainState(&game)
 	tyumi.Run()
@@ -59,6 +69,7 @@ const (
 	PLAYING
 	PAUSED
 	GAME_OVER
+	REPLAYING
 )
 
 type TyTris struct {
@@ -66,40 +77,48 @@ type TyTris struct {
 
 	state int // one of the constants above
 
+	// players. the solo player is used for everything except local versus games, where left and right share the
+	// screen.
+	solo    Player
+	left    Player
+	right   Player
+	players []*Player // the players in the current game
+
 	//ui elements
-	playField     PlayField
-	matrixView    MatrixView
-	upcomingArea  UpcomingPieceView
-	heldArea      GridArea
+	logo          ui.Image
+	subtitle      *ui.Textbox
 	highScoreArea HighScoreView
+	opponentView  OpponentView // shown in place of the high scores in versus games
 
-	//animations
-	held_flash gfx.FlashAnimation
-
-	current_piece   Piece
-	held_piece      Piece
-	ghost_position  vec.Coord
-	matrix          []Line
-	upcoming_pieces []Piece
-	highScores      HighScores
-
-	info             GameInfo
-	piece_spawn_tick int
-	gravity          int
-	speed_up         bool // will be true if player is holding down the DOWN key
-	swapped_piece    bool // whether or not a swap has taken place for this piece
-	dropped_piece    bool // true if player is doing a hard drop
-	spawn_next       bool // true if a new piece needs to be spawned
+	mode        Mode       // mode of the game being played (or replayed)
+	puzzle_pack PuzzlePack // the pack the puzzle being played is from, for MODE_PUZZLE
+
+	//replay playback
+	replay_player engine.ReplayPlayer
+	replay_speed  int // index into replay_speeds
+	replay_paused bool
+	replay_clock  int // half-ticks of playback owed to the replay
+
+	//versus
+	versus         *versus.Session // connection to the opponent, for MODE_VERSUS
+	versus_pending *versus.Pending // connection being set up from the versus menu
+	versus_won     bool            // the opponent lost, or left
+	versus_forfeit bool            // the opponent left before the game was decided
+
+	highScores HighScores
+	settings   Settings
 }
 
 func (t *TyTris) setup() {
 	t.Events().AddHandler(t.handle_event)
-	t.Events().Listen(EV_CHANGESTATE, EV_HIGHSCORE)
+	t.Events().Listen(EV_CHANGESTATE, EV_HIGHSCORE, EV_PLAYREPLAY, EV_NEWGAME, EV_VERSUS)
 
 	// do some game and ui setup
-	t.matrix = make([]Line, well_size.H)
-	for i := range t.matrix {
-		t.matrix[i].Clear()
+	t.solo = Player{name: "You", keys: solo_keys}
+	t.left = Player{name: "Left", keys: left_keys, split: true}
+	t.right = Player{name: "Right", keys: right_keys, split: true}
+	for _, player := range []*Player{&t.solo, &t.left, &t.right} {
+		player.game.Init(t.settings.gameConfig(t.mode))
 	}
 
 	//load high scores! (if they exist)
@@ -114,6 +133,7 @@ func (t *TyTris) setup() {
 	sounds.Get("drop").SetVolume(68)
 	sounds.Get("lock").SetVolume(43)
 	sounds.Get("enter").SetVolume(20)
+	sounds.Get("tick").SetVolume(50)
 
 	//load and configure music!
 	playingMusic = tyumi.LoadMusic("res/tytris-theme.wav")
@@ -121,6 +141,7 @@ func (t *TyTris) setup() {
 	menuMusic = tyumi.LoadMusic("res/tytris-menu.wav")
 	menuMusic.Looping = true
 	gameOverMusic = tyumi.LoadMusic("res/tytris-sad.wav")
+	victoryMusic = tyumi.LoadMusic("res/tytris-victory.wav")
 	tyumi.PlayMusic(menuMusic)
 
 	t.setupUI()
@@ -134,15 +155,70 @@ func (t *TyTris) changeState(new_state int) {
 	switch new_state {
 	case GAME_START:
 		t.cleanupUI()
+		t.setPlayers(false)
 		ui.GetLabelled[*MainMenu](t.Window(), "menu").Activate(GAME_START)
 		tyumi.PlayMusic(menuMusic)
 	case GAME_OVER:
 		log.Debug("GAME OVER")
 		t.SetInputHandler(nil)
-		tyumi.PlayMusic(gameOverMusic)
-		t.info.high_score = t.highScores.IsHighScore(t.info.score)
+		player := t.players[0]
+		info := player.game.Info()
 		ui.GetLabelled[*MainMenu](t.Window(), "menu").Hide()
-		ui.GetLabelled[*GameOverScreen](t.Window(), "gameover").Activate(t.info)
+		for _, p := range t.players {
+			p.matrixView.Reveal(-1)
+			p.stopBot()
+		}
+		result := Result{
+			Mode:    t.mode,
+			Info:    info,
+			Ending:  player.game.Ending(),
+			Cleared: player.game.GoalReached(),
+			Replay:  t.state == REPLAYING,
+		}
+
+		if t.mode.Type == MODE_MASTER {
+			result.Grade = player.grader.Grade(info)
+		}
+
+		if t.versus != nil {
+			result.Opponent = t.versus.Opponent
+			result.Won = t.versus_won
+			result.Forfeit = t.versus_forfeit
+			t.endVersus()
+		}
+
+		if t.mode.Local {
+			t.localResult(&result)
+		}
+
+		if t.mode.Type == MODE_PUZZLE && result.Cleared && !result.Replay {
+			markPuzzleSolved(t.mode.Pack, t.puzzle_pack.Puzzles[t.mode.Puzzle].Name)
+			if t.mode.Puzzle+1 < len(t.puzzle_pack.Puzzles) {
+				next := t.mode
+				next.Puzzle += 1
+				result.Next = &next
+			}
+		}
+
+		// finishing a mode is a happy occasion, even if you came a cropper after playing past the goal
+		if result.Cleared || result.Won || result.Ending == engine.ENDING_TIME_UP {
+			tyumi.PlayMusic(victoryMusic)
+		} else {
+			tyumi.PlayMusic(gameOverMusic)
+		}
+
+		if result.Replay {
+			player.callout.Hide()
+		} else if !t.mode.Local { // a replay only holds one player's game, so local versus games aren't saved
+			t.saveReplay()
+			// the computer doesn't get to put its name on the leaderboards
+			result.HighScore = !t.mode.AI && t.highScores.IsHighScore(t.mode, result.Entry())
+			if best, ok := t.highScores.Best(t.mode); ok {
+				result.Best = best
+			}
+		}
+
+		ui.GetLabelled[*GameOverScreen](t.Window(), "gameover").Activate(result)
 	case NEW_GAME:
 		t.new_game()
 		return
@@ -166,6 +242,14 @@ func (t *TyTris) changeState(new_state int) {
 		tyumi.PauseMusic()
 		ui.GetLabelled[*MainMenu](t.Window(), "menu").Activate(PAUSED)
 		t.SetInputHandler(nil)
+		for _, player := range t.players {
+			player.addAction(engine.ACTION_PAUSE)
+			player.releaseHeldInputs()
+		}
+	case REPLAYING:
+		tyumi.PlayMusic(playingMusic)
+		ui.GetLabelled[*MainMenu](t.Window(), "menu").Hide()
+		t.SetInputHandler(t.handleInput_replay)
 	default:
 		log.Error("Oops, bad state change.")
 		return
@@ -174,306 +258,205 @@ func (t *TyTris) changeState(new_state int) {
 	t.state = new_state
 }
 
-func (t *TyTris) new_game() {
-	t.info = GameInfo{}
-	t.shuffle_pieces()
-	t.gravity = starting_gravity
-	t.spawn_next = true
-	t.held_piece.pType = NO_PIECE
-	fireStateChangeEvent(PLAYING)
-}
-
-func (t *TyTris) Update() {
-	if t.state != PLAYING {
+// localResult decides who won a local versus game. Whoever is still standing wins, so if both players topped out at
+// once (or somebody gave up) nobody does.
+func (t *TyTris) localResult(result *Result) {
+	var winner, loser *Player
+	switch left_out, right_out := t.left.game.IsOver(), t.right.game.IsOver(); {
+	case left_out && !right_out:
+		winner, loser = &t.right, &t.left
+	case right_out && !left_out:
+		winner, loser = &t.left, &t.right
+	default:
 		return
 	}
 
-	if t.spawn_next {
-		//test for game over
-		for i := range invalid_lines {
-			if t.matrix[i].hasBlock() {
-				fireStateChangeEvent(GAME_OVER)
-				return
-			}
-		}
-
-		t.cleanMatrix()
-
-		t.spawn_piece(t.get_next_piece())
-		t.spawn_next = false
-	}
+	result.Info = winner.game.Info()
+	result.Ending = winner.game.Ending()
+	result.Won = true
+	result.Winner = winner.name
+	result.Opponent = loser.name
+}
 
-	if t.current_piece.pType == NO_PIECE {
-		return
+// setMode changes the mode the next game will be played in.
+func (t *TyTris) setMode(mode Mode) {
+	if mode.Type == MODE_SURVIVAL && mode.Curve == "" {
+		mode.Curve = t.settings.RiseCurve
 	}
 
-	//apply gravity
-	current_gravity := t.gravity
-	if t.speed_up && t.gravity > speed_up_gravity {
-		current_gravity = speed_up_gravity
+	if mode.Big && mode.Type == MODE_PUZZLE {
+		log.Info("Puzzles are made for the normal well, playing without big mode.")
+		mode.Big = false
+	} else if mode.Big && t.settings.WellSize.W < big_min_well_width {
+		log.Info("The well is too narrow for big mode, playing without it.")
+		mode.Big = false
 	}
 
-	if (t.info.time-t.piece_spawn_tick)%current_gravity == 0 {
-		if t.testMove(vec.DIR_DOWN) {
-			t.movePiece(vec.DIR_DOWN)
+	if mode.Type == MODE_PUZZLE {
+		pack, err := loadPuzzlePack(mode.Pack, t.settings.WellSize.W)
+		if err != nil || mode.Puzzle >= len(pack.Puzzles) {
+			log.Error("Could not load puzzle pack ", mode.Pack, ": ", err)
+			mode = Mode{Type: MODE_ENDLESS}
 		} else {
-			t.lockPiece()
+			t.puzzle_pack = pack
 		}
 	}
 
-	t.info.time += 1
-}
-
-func (t *TyTris) updateScore(lines_destroyed int) {
-	points := lines_destroyed * 10
-	//do more score stuff here????
-
-	t.info.score += points
-	score_text := ui.GetLabelled[*ui.Textbox](t.Window(), "score")
-	score_text.ChangeText(strconv.Itoa(t.info.score))
-	pulse := gfx.NewPulseAnimation(score_text.DrawableArea(), 0, 12, col.Pair{col.YELLOW, col.NONE})
-	pulse.OneShot = true
-	pulse.Start()
-	score_text.AddAnimation(&pulse)
-}
-
-func (t *TyTris) cleanupUI() {
-	for i := range t.matrix {
-		t.matrix[i].Clear()
+	t.mode = mode
+	t.setPlayers(mode.Local)
+	for _, player := range t.players {
+		player.setMode(mode, t.settings.FadeTime)
 	}
-	t.matrixView.Updated = true
-
-	t.upcomingArea.Reset()
-
-	t.held_piece = Piece{pType: NO_PIECE}
-	ui.GetLabelled[*PieceElement](t.Window(), "held").UpdatePiece(t.held_piece)
-	t.heldArea.Updated = true
 
-	ui.GetLabelled[*ui.Textbox](t.Window(), "score").ChangeText("0")
-	ui.GetLabelled[*ui.Textbox](t.Window(), "time").ChangeText("0")
-	ui.GetLabelled[*ui.Textbox](t.Window(), "speed").ChangeText("0")
-}
-
-func (t *TyTris) testRotate(dir int) (kick vec.Coord, ok bool) {
-	test_piece := t.current_piece
-	test_piece.Rotate(dir)
-	if t.testValidPosition(test_piece) {
-		return vec.ZERO_COORD, true
-	}
-
-	//try kicks
-	for _, test_kick := range test_piece.GetKicks() {
-		test_piece.pos.Move(test_kick.X, test_kick.Y)
-		if t.testValidPosition(test_piece) {
-			return test_kick, true
-		}
-		test_piece.pos = test_piece.pos.Subtract(test_kick)
+	if mode.Type == MODE_PUZZLE {
+		t.highScoreArea.ShowPuzzle(t.puzzle_pack.Puzzles[mode.Puzzle])
+	} else {
+		t.highScoreArea.UpdateScores(t.highScores, mode)
 	}
 
-	return
-}
-
-func (t *TyTris) testMove(dir vec.Direction) bool {
-	test_piece := t.current_piece
-	test_piece.pos = test_piece.pos.Step(dir)
-	return t.testValidPosition(test_piece)
-}
-
-func (t *TyTris) testValidPosition(piece Piece) bool {
-	piece_shape := piece.GetShape()
-	for i, block := range piece_shape {
-		if block {
-			block_pos := piece.pos.Add(vec.IndexToCoord(i, piece.Stride()))
-			//not in well
-			if !block_pos.IsInside(well_size) {
-				return false
-			}
-
-			//collide with matrix
-			if t.matrix[block_pos.Y].blocks[block_pos.X] != NO_PIECE {
-				return false
-			}
+	if mode.Type == MODE_VERSUS && !mode.Local {
+		if t.versus == nil { // watching a replay, the opponent's side of it wasn't recorded
+			t.opponentView.SetOpponent("")
 		}
+		t.highScoreArea.Hide()
+		t.opponentView.Show()
 	}
-
-	return true
 }
 
-func (t *TyTris) lockPiece() {
-	//write piece in current position to lines buffers
-	piece_shape := t.current_piece.GetShape()
-	for i, block := range piece_shape {
-		if block {
-			block_pos := t.current_piece.pos.Add(vec.IndexToCoord(i, t.current_piece.Stride()))
-			t.matrix[block_pos.Y].blocks[block_pos.X] = t.current_piece.pType
-		}
-	}
-	t.matrixView.Updated = true
-
-	t.current_piece.pType = NO_PIECE
-	ui.GetLabelled[*PieceElement](t.Window(), "current piece").UpdatePiece(t.current_piece)
-
-	//test for full lines
-	var destroyed_lines int
-	for i, line := range t.matrix {
-		if line.isFull() {
-			lda := NewLineDestroyAnimation(vec.Rect{vec.Coord{0, i}, vec.Dims{well_size.W, 1}})
-			t.playField.AddAnimation(&lda)
-			destroyed_lines += 1
-		}
-	}
-
-	if destroyed_lines > 0 {
-		t.info.lines_destroyed += destroyed_lines
-		switch destroyed_lines {
-		case 2:
-			t.info.double_kills += 1
-		case 3:
-			t.info.triple_kills += 1
-		case 4:
-			t.info.quad_kills += 1
-		}
-		t.updateScore(destroyed_lines)
-
-		sounds.Play("kill")
+// setPlayers switches between the solo player and the two local versus players. The left player's side of the
+// screen covers the logo and high scores, so they're hidden while it's showing.
+func (t *TyTris) setPlayers(split bool) {
+	if split {
+		t.players = []*Player{&t.left, &t.right}
+		t.solo.Hide()
+		t.logo.Hide()
+		t.subtitle.Hide()
+		t.highScoreArea.Hide()
+		t.opponentView.Hide()
 	} else {
-		if t.dropped_piece {
-			sounds.Play("drop")
-			t.dropped_piece = false
-		} else {
-			sounds.Play("lock")
-		}
+		t.players = []*Player{&t.solo}
+		t.left.Hide()
+		t.right.Hide()
+		t.logo.Show()
+		t.subtitle.Show()
+		t.highScoreArea.Show()
+		t.opponentView.Hide()
 	}
 
-	t.info.pieces_dropped += 1
-	t.spawn_next = true
+	for _, player := range t.players {
+		player.Show()
+	}
 }
 
-func (t *TyTris) cleanMatrix() {
-	for i, line := range t.matrix {
-		if line.isFull() {
-			t.destroyLine(i)
-		}
+// opponent returns the player's opponent in a local versus game, or nil if there isn't one.
+func (t *TyTris) opponent(player *Player) *Player {
+	switch player {
+	case &t.left:
+		return &t.right
+	case &t.right:
+		return &t.left
+	default:
+		return nil
 	}
-
-	t.playField.Updated = true
 }
 
-func (t *TyTris) destroyLine(line_index int) {
-	for i := line_index - 1; i > 0; i-- {
-		if t.matrix[i].hasBlock() {
-			t.matrix[i+1] = t.matrix[i]
-		} else {
-			t.matrix[i+1].Clear()
-			break
-		}
+func (t *TyTris) new_game() {
+	config := t.settings.gameConfig(t.mode)
+	if t.versus != nil {
+		config.Seed = t.versus.Seed // both players get the same pieces
+	} else if t.mode.Local && config.Seed == 0 {
+		config.Seed = rand.Int63n(1e9) // same here, so the seed is picked once for both of them
 	}
 
-	t.matrixView.Updated = true
-}
-
-func (t *TyTris) updateGhost() {
-	test_piece := t.current_piece
-	test_piece.pos = test_piece.pos.Step(vec.DIR_DOWN)
-	for {
-		if t.testValidPosition(test_piece) {
-			test_piece.pos = test_piece.pos.Step(vec.DIR_DOWN)
-		} else {
-			t.ghost_position = test_piece.pos.Step(vec.DIR_UP)
-			break
+	if t.mode.Local && t.mode.AI {
+		// nobody to share the keyboard with, so the player gets the usual keys
+		t.left.name, t.left.keys = t.settings.PlayerName, solo_keys
+		t.right.name = "CPU"
+		if t.mode.Bot != "" {
+			t.right.name = t.mode.Bot
 		}
+	} else if t.mode.Local {
+		t.left.name, t.left.keys = "Left", left_keys
+		t.right.name = "Right"
 	}
 
-	test_piece.pos = t.ghost_position
-	ui.GetLabelled[*PieceElement](t.Window(), "ghost").UpdatePiece(test_piece)
-}
-
-// adds a shuffled set of the 7 pieces to the upcoming piece list
-func (t *TyTris) shuffle_pieces() {
-	pieces := []PieceType{I, J, Z, S, T, O, L}
-	//pieces := []PieceType{T, T, T, T, T, T, T}
-	//pieces := []PieceType{O, O, O, O, O, O, O}
-	rand.Shuffle(len(pieces), func(i, j int) {
-		pieces[i], pieces[j] = pieces[j], pieces[i]
-	})
-
-	for i := range pieces {
-		t.upcoming_pieces = append(t.upcoming_pieces, Piece{
-			pType: pieces[i],
-		})
+	for _, player := range t.players {
+		player.newGame(config)
+		player.stopBot()
 	}
-}
 
-func (t *TyTris) spawn_piece(piece Piece) {
-	t.current_piece = piece
-	t.current_piece.pos = piece.StartLocation()
-	t.swapped_piece = false
-
-	t.updateGhost()
-	ui.GetLabelled[*PieceElement](t.Window(), "current piece").UpdatePiece(t.current_piece)
-
-	//update gravity if necessary
-	old_gravity := t.gravity
-	t.gravity = util.Clamp(starting_gravity-t.info.time/acceleration_time, gravity_minimum, starting_gravity)
-	if t.gravity != old_gravity {
-		speedup_animation := NewSpeedUpAnimation()
-		t.playField.AddAnimation(&speedup_animation)
-		sounds.Play("speedup")
+	// the computer plays the right side in versus games, and the only side otherwise
+	if t.mode.AI {
+		player := t.players[len(t.players)-1]
+		player.bot = t.settings.newBot(t.mode.Bot, player.game.Info().Seed)
 	}
 
-	t.piece_spawn_tick = t.info.time
-}
-
-func (t *TyTris) get_next_piece() Piece {
-	piece := t.upcoming_pieces[0]
-	t.upcoming_pieces = slices.Delete(t.upcoming_pieces, 0, 1)
-	if len(t.upcoming_pieces) < 6 {
-		t.shuffle_pieces()
+	if t.mode.Local && t.mode.AI {
+		t.right.callout.Flash(t.right.name, text_colour, 180)
+	} else if t.mode.Local {
+		t.left.callout.Flash("WASD+QE", text_colour, 180)
+		t.right.callout.Flash("ARROWS+,.", text_colour, 180)
 	}
-	t.upcomingArea.UpdatePieces(t.upcoming_pieces[0:6])
 
-	return piece
+	fireStateChangeEvent(PLAYING)
 }
 
-type Line struct {
-	blocks [10]PieceType
-}
+func (t *TyTris) Update() {
+	t.updateVersus()
 
-func (l *Line) Clear() {
-	for i := range l.blocks {
-		l.blocks[i] = NO_PIECE
+	// the opponent doesn't stop just because you did, so updateVersus keeps listening while paused. garbage they send
+	// waits with the player's other actions until the game carries on.
+	switch t.state {
+	case PLAYING:
+		for _, player := range t.players {
+			if player.bot != nil {
+				player.addAction(player.bot.Actions(&player.game)...)
+			}
+			t.step(player, player.pending_actions)
+			player.pending_actions = player.pending_actions[:0]
+		}
+	case REPLAYING:
+		t.updateReplay()
 	}
 }
 
-func (l Line) isFull() bool {
-	for _, block := range l.blocks {
-		if block == NO_PIECE {
-			return false
+// step advances a player's game by one tick and reacts to whatever happened. Returns true if any lines were cleared.
+func (t *TyTris) step(player *Player, actions []engine.Action) (cleared bool) {
+	for _, e := range player.step(actions) {
+		t.handleGameEvent(player, e)
+		if e.Type == engine.EV_PIECE_LOCKED && len(e.Lines) > 0 {
+			cleared = true
 		}
 	}
-	return true
+
+	return
 }
 
-func (l Line) hasBlock() bool {
-	for _, block := range l.blocks {
-		if block != NO_PIECE {
-			return true
+// handleGameEvent deals with the parts of the game that reach beyond the player's own side of the screen: attacking
+// the opponent and ending the game.
+func (t *TyTris) handleGameEvent(player *Player, e engine.Event) {
+	switch e.Type {
+	case engine.EV_PIECE_LOCKED, engine.EV_LINES_DESTROYED, engine.EV_GARBAGE_RISEN:
+		t.sendBoard()
+	case engine.EV_ATTACK:
+		if opponent := t.opponent(player); opponent != nil {
+			for range e.Attack {
+				opponent.addAction(engine.ACTION_GARBAGE)
+			}
+		} else {
+			t.sendVersus(versus.Message{Type: versus.MSG_ATTACK, Lines: e.Attack})
+		}
+	case engine.EV_GOAL_REACHED:
+		if player.game.IsOver() {
+			fireStateChangeEvent(GAME_OVER)
 		}
+	case engine.EV_TOP_OUT, engine.EV_TIME_UP, engine.EV_OUT_OF_PIECES:
+		fireStateChangeEvent(GAME_OVER)
 	}
-
-	return false
 }
 
-type GameInfo struct {
-	score           int
-	time            int
-	pieces_dropped  int
-	quick_drops     int
-	swaps           int
-	lines_destroyed int
-	double_kills    int
-	triple_kills    int
-	quad_kills      int
-
-	high_score bool
+func (t *TyTris) cleanupUI() {
+	for _, player := range t.players {
+		player.cleanupUI()
+	}
 }
@@ -1,8 +1,6 @@
 package main
 
 import (
-	"strconv"
-
 	"github.com/bennicholls/tyumi/gfx"
 	"github.com/bennicholls/tyumi/gfx/col"
 	"github.com/bennicholls/tyumi/gfx/ui"
@@ -16,7 +14,72 @@ var text_colour uint32 = col.MakeOpaque(222, 211, 195)
 var grid_colour uint32 = col.MakeOpaque(26, 26, 26)
 var invalid_line_colour uint32 = col.MakeOpaque(51, 51, 51)
 
+// the playfield is given at least this much room in the layout, so the menus that cover it still fit when playing in
+// a small well.
+var playfield_slot_minimum vec.Dims = vec.Dims{10, 25}
+
+// layout describes where the major pieces of the ui go. everything is arranged around the playfield, so the layout
+// depends on the size of the well. the console is made wide enough for the local versus layout too, since it can't
+// change size once the game is running.
+type layout struct {
+	console vec.Dims
+	slot    vec.Rect        // area reserved for the playfield. the main menu covers this area
+	solo    playerLayout    // for single player games
+	split   [2]playerLayout // for local versus games, with the players side by side
+}
+
+// playerLayout describes where one player's part of the ui goes.
+type playerLayout struct {
+	playfield vec.Coord
+	meter     vec.Coord // garbage meter, beside the playfield
+	callout   vec.Rect
+	upcoming  vec.Rect
+	held      vec.Coord
+	info      vec.Rect
+}
+
+// width of the columns beside each playfield in the local versus layout. just enough room for 2 upcoming pieces
+const split_column_width int = 9
+
+func computeLayout(well_size vec.Dims) (l layout) {
+	l.slot = vec.Rect{vec.Coord{19, 1}, vec.Dims{max(well_size.W, playfield_slot_minimum.W), max(well_size.H, playfield_slot_minimum.H)}}
+
+	l.solo.playfield = l.slot.Coord
+	l.solo.playfield.Move((l.slot.W-well_size.W)/2, (l.slot.H-well_size.H)/2)
+	l.solo.meter = vec.Coord{l.solo.playfield.X - 2, l.solo.playfield.Y}
+	l.solo.callout = vec.Rect{vec.Coord{l.slot.X, l.slot.Y + 6}, vec.Dims{l.slot.W, 1}}
+	right_column := l.slot.X + l.slot.W + 1
+	l.solo.upcoming = vec.Rect{vec.Coord{right_column, 3}, vec.Dims{18, 4}}
+	l.solo.held = vec.Coord{right_column, 8}
+	l.solo.info = vec.Rect{vec.Coord{right_column + 2, 17}, vec.Dims{14, 8}}
+
+	// the players face each other across the middle of the screen, with their columns on the outside
+	column := vec.Coord{1, 0}
+	for i := range l.split {
+		pl := &l.split[i]
+		if i == 0 {
+			pl.meter = vec.Coord{column.X + split_column_width + 1, l.solo.playfield.Y}
+			pl.playfield = vec.Coord{pl.meter.X + 2, l.solo.playfield.Y}
+		} else {
+			pl.playfield = vec.Coord{l.split[0].playfield.X + well_size.W + 2, l.solo.playfield.Y}
+			pl.meter = vec.Coord{pl.playfield.X + well_size.W + 1, l.solo.playfield.Y}
+			column.X = pl.meter.X + 2
+		}
+		pl.callout = vec.Rect{vec.Coord{pl.playfield.X, l.slot.Y + 6}, vec.Dims{well_size.W, 1}}
+		pl.upcoming = vec.Rect{vec.Coord{column.X, 3}, vec.Dims{split_column_width, 4}}
+		pl.held = vec.Coord{column.X + (split_column_width-6)/2, 9}
+		pl.info = vec.Rect{vec.Coord{column.X, 16}, vec.Dims{split_column_width, 8}}
+	}
+
+	l.console = vec.Dims{max(right_column+18, column.X+split_column_width+1), l.slot.H + 2}
+
+	return
+}
+
 func (t *TyTris) setupUI() {
+	well_size := t.settings.WellSize
+	layout := computeLayout(well_size)
+
 	//define a custom border style (derived from one of the provided borderstyles) and set it as the default for all
 	//borders
 	tytris_border := ui.BorderStyles["Thin"]
@@ -24,94 +87,41 @@ func (t *TyTris) setupUI() {
 	ui.SetDefaultBorderStyle(tytris_border)
 
 	//logo and subtitle
-	logoImage := ui.Image{}
-	logoImage.Init(vec.Coord{3, 2}, 0, "res/logo.xp")
-	subtitle := ui.NewTextbox(vec.Dims{10, ui.FIT_TEXT}, vec.Coord{4, 9}, 0, "The Fun Game That No One Stole At All", true)
-	subtitle.SetDefaultColours(col.Pair{text_colour, gfx.COL_DEFAULT})
-	t.Window().AddChildren(&logoImage, subtitle)
-
-	//initialize the playfield, where the blocks fall and the matrix is drawn.
-	t.playField.Init(well_size, vec.Coord{19, 1}, 0)
-	t.playField.EnableBorder()
-
-	t.matrixView.Init(well_size, vec.ZERO_COORD, 2)
-	t.matrixView.matrix = &t.matrix
-
-	t.playField.AddChild(&t.matrixView)
-
-	current_piece := PieceElement{}
-	current_piece.Init(vec.Dims{3, 2}, vec.Coord{0, 0}, 2)
-	current_piece.SetLabel("current piece")
-	t.playField.AddChild(&current_piece)
-
-	ghost_piece := PieceElement{
-		ghost: true,
-	}
-	ghost_piece.Init(vec.Dims{3, 2}, vec.Coord{0, 0}, 1)
-	ghost_piece.SetLabel("ghost")
-	t.playField.AddChild(&ghost_piece)
-
-	//main menu. this will be a child of the playarea, blocking the view of the matrix and everything else when
-	//visibility is toggled on. we'll also use this as the pause menu, with a change in some text
+	t.logo.Init(vec.Coord{3, 2}, 0, "res/logo.xp")
+	t.subtitle = ui.NewTextbox(vec.Dims{10, ui.FIT_TEXT}, vec.Coord{4, 9}, 0, "The Fun Game That No One Stole At All", true)
+	t.subtitle.SetDefaultColours(col.Pair{text_colour, gfx.COL_DEFAULT})
+	t.Window().AddChildren(&t.logo, t.subtitle)
+
+	// the players' playfields, pieces and info. only the solo player is shown until a local versus game starts
+	t.solo.setupUI(t.Window(), well_size, layout.solo)
+	t.left.setupUI(t.Window(), well_size, layout.split[0])
+	t.right.setupUI(t.Window(), well_size, layout.split[1])
+
+	//main menu. this covers the playfield, blocking the view of the matrix and everything else when visibility is
+	//toggled on. we'll also use this as the pause menu, with a change in some text
 	mainMenu := MainMenu{}
-	mainMenu.Init(t.playField.DrawableArea().Dims)
-	t.playField.AddChild(&mainMenu)
-
-	t.Window().AddChild(&t.playField)
-
-	// upcoming pieces view, where up to the next 6 pieces are displayed in order from left to right
-	t.upcomingArea.Init(vec.Dims{18, 4}, vec.Coord{30, 3}, 0)
-
-	// held piece area, where we show which piece the player is holding (if there is one)
-	t.heldArea.Init(vec.Dims{6, 4}, vec.Coord{30, 8}, 0)
-	t.heldArea.SetupBorder("", "held piece")
-	t.held_flash = gfx.NewFlashAnimation(t.heldArea.DrawableArea(), 1, col.Pair{col.NONE, col.NONE}, 15)
-	t.heldArea.AddAnimation(&t.held_flash)
-
-	held_piece := PieceElement{}
-	held_piece.Init(vec.Dims{3, 2}, vec.Coord{1, 1}, 1)
-	held_piece.SetLabel("held")
-	t.heldArea.AddChild(&held_piece)
-
-	t.Window().AddChildren(&t.upcomingArea, &t.heldArea)
-
-	// info area, where we show the player's score, as well as the timer and current game speed
-	infoArea := ui.Element{}
-	infoArea.Init(vec.Dims{14, 6}, vec.Coord{32, 19}, 1)
-	infoArea.EnableBorder()
-
-	scoreLabel := ui.NewTextbox(vec.Dims{14, 1}, vec.Coord{0, 0}, 1, "S C O R E", true)
-	scoreLabel.SetDefaultColours(col.Pair{text_colour, col.NONE})
-	score := ui.NewTextbox(vec.Dims{14, 1}, vec.Coord{0, 1}, 1, "0", true)
-	score.SetLabel("score")
-	score.SetDefaultColours(col.Pair{text_colour, col.NONE})
-	infoArea.AddChildren(scoreLabel, score)
-
-	timeLabel := ui.NewTextbox(vec.Dims{14, 1}, vec.Coord{0, 2}, 1, "T I M E R", true)
-	timeLabel.SetDefaultColours(col.Pair{text_colour, border_colour})
-	time := ui.NewTextbox(vec.Dims{14, 1}, vec.Coord{0, 3}, 1, "0", true)
-	time.SetLabel("time")
-	time.SetDefaultColours(col.Pair{text_colour, border_colour})
-	infoArea.AddChildren(timeLabel, time)
-
-	speedLabel := ui.NewTextbox(vec.Dims{14, 1}, vec.Coord{0, 4}, 1, "S P E E D", true)
-	speedLabel.SetDefaultColours(col.Pair{text_colour, col.NONE})
-	speed := ui.NewTextbox(vec.Dims{14, 1}, vec.Coord{0, 5}, 1, "0", true)
-	speed.SetLabel("speed")
-	speed.SetDefaultColours(col.Pair{text_colour, col.NONE})
-	infoArea.AddChildren(speedLabel, speed)
-
-	t.Window().AddChild(&infoArea)
+	mainMenu.Init(layout.slot.Dims, layout.slot.Coord, 3)
+	mainMenu.SetBots(t.settings.botNames())
+	mainMenu.well_width = well_size.W
+	mainMenu.marathon_goal = t.settings.MarathonGoal
+	t.Window().AddChild(&mainMenu)
 
 	// highscore area
 	t.highScoreArea.Init(vec.Dims{12, 13}, vec.Coord{3, 13}, 1)
 	t.highScoreArea.EnableBorder()
-	t.highScoreArea.UpdateScores(t.highScores)
+	t.highScoreArea.UpdateScores(t.highScores, t.mode)
 	t.Window().AddChild(&t.highScoreArea)
 
+	// the opponent's board takes the place of the high scores in versus games
+	t.opponentView.Init(vec.Dims{12, 13}, vec.Coord{3, 13}, 1)
+	t.Window().AddChild(&t.opponentView)
+
 	gameover := GameOverScreen{}
-	gameover.Init(t.Window().DrawableArea().Dims.Shrink(14, 14), vec.Coord{7, 7}, 10)
+	gameover_size := vec.Dims{34, 20}
+	gameover.Init(gameover_size, vec.Coord{(layout.console.W - gameover_size.W) / 2, (layout.console.H - gameover_size.H) / 2}, 10)
 	t.Window().AddChild(&gameover)
+
+	t.setMode(t.mode)
 }
 
 func drawBlock(canvas *gfx.Canvas, block_pos vec.Coord, glyph gfx.Glyph, colour, highlight uint32) {
@@ -119,17 +129,11 @@ func drawBlock(canvas *gfx.Canvas, block_pos vec.Coord, glyph gfx.Glyph, colour,
 }
 
 func (t *TyTris) UpdateUI() {
-	if t.state != PLAYING {
+	if t.state != PLAYING && t.state != REPLAYING {
 		return
 	}
 
-	timer := ui.GetLabelled[*ui.Textbox](t.Window(), "time")
-	timer.ChangeText(strconv.Itoa(t.info.time / 60))
-
-	speed := ui.GetLabelled[*ui.Textbox](t.Window(), "speed")
-	if t.gravity != gravity_minimum {
-		speed.ChangeText(strconv.Itoa(starting_gravity - t.gravity))
-	} else {
-		speed.ChangeText("MAXIMUM SPEED!!")
+	for _, player := range t.players {
+		player.updateInfo()
 	}
 }
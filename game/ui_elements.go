@@ -2,10 +2,13 @@ package main
 
 import (
 	"fmt"
+	"strconv"
 
+	"github.com/bennicholls/tytris/engine"
 	"github.com/bennicholls/tyumi/gfx"
 	"github.com/bennicholls/tyumi/gfx/col"
 	"github.com/bennicholls/tyumi/gfx/ui"
+	"github.com/bennicholls/tyumi/util"
 	"github.com/bennicholls/tyumi/vec"
 )
 
@@ -24,8 +27,45 @@ func (ga *GridArea) Render() {
 	}
 }
 
+// WellGrid maps positions in the well to cells in the playfield. Normally each block is one cell, but in big mode
+// every block covers a square of cells.
+type WellGrid struct {
+	scale  int       // cells per block along each side. 0 is treated as 1
+	origin vec.Coord // cell the top-left block of the well is drawn at
+}
+
+func (wg WellGrid) Scale() int {
+	return max(wg.scale, 1)
+}
+
+// Cell returns the top-left cell of the block at pos.
+func (wg WellGrid) Cell(pos vec.Coord) vec.Coord {
+	return pos.Scale(wg.Scale()).Add(wg.origin)
+}
+
+// Block returns the position of the block drawn over the provided cell. ok is false for cells above or to the left
+// of the well, which can happen when the playfield doesn't divide evenly into blocks.
+func (wg WellGrid) Block(cell vec.Coord) (pos vec.Coord, ok bool) {
+	cell = cell.Subtract(wg.origin)
+	if cell.X < 0 || cell.Y < 0 {
+		return
+	}
+
+	return vec.Coord{cell.X / wg.Scale(), cell.Y / wg.Scale()}, true
+}
+
 type PlayField struct {
 	GridArea
+
+	grid          WellGrid
+	invalid_lines int
+}
+
+// SetGrid changes how the well is laid out over the playfield, moving the invalid line to match.
+func (pf *PlayField) SetGrid(grid WellGrid, invalid_lines int) {
+	pf.grid = grid
+	pf.invalid_lines = invalid_lines
+	pf.Updated = true
 }
 
 func (pf *PlayField) Render() {
@@ -33,8 +73,9 @@ func (pf *PlayField) Render() {
 
 	//draw invalid line
 	invalid_brush := gfx.NewGlyphVisuals(gfx.GLYPH_LOWERCURSOR, col.Pair{invalid_line_colour, col.NONE})
-	for i := range 10 {
-		pf.DrawVisuals(vec.Coord{i, invalid_lines - 1}, 0, invalid_brush)
+	y := pf.grid.Cell(vec.Coord{0, pf.invalid_lines}).Y - 1
+	for i := range pf.Size().W {
+		pf.DrawVisuals(vec.Coord{i, y}, 0, invalid_brush)
 	}
 
 }
@@ -42,23 +83,201 @@ func (pf *PlayField) Render() {
 type MatrixView struct {
 	ui.Element
 
-	matrix *[]Line
+	board  *engine.Board
+	pieces engine.PieceSet // the pieces the game is played with, for the colours of their blocks
+	grid   WellGrid
+
+	// for fading and invisible stacks
+	stack           StackModifier
+	fade_time       int // ticks blocks stay visible for before fading, for STACK_FADING
+	time            int // the game's current tick, for working out how long ago blocks were locked
+	reveal_timer    int // ticks since the stack was revealed
+	reveal_duration int // ticks to reveal the stack for. 0 if it isn't being revealed, -1 to reveal it until further notice
+}
+
+// ticks a block takes to fade away in a fading stack
+const stack_fade_duration int = 30
+
+// ticks the stack is revealed for when lines are cleared, fading in and back out
+const line_clear_reveal_duration int = 60
+
+// SetGrid changes how blocks are laid out over the view.
+func (m *MatrixView) SetGrid(grid WellGrid) {
+	m.grid = grid
+	m.Updated = true
+}
+
+// SetStack changes how locked blocks are shown.
+func (m *MatrixView) SetStack(stack StackModifier, fade_time int) {
+	m.stack = stack
+	m.fade_time = fade_time
+	m.Updated = true
+}
+
+// SetTime keeps the view in sync with the game's clock, so blocks can fade as they get older.
+func (m *MatrixView) SetTime(time int) {
+	m.time = time
+	if m.stack != STACK_VISIBLE {
+		m.Updated = true
+	}
+}
+
+// Reveal fades in the whole stack, even the blocks that have faded away, for the provided number of ticks. A duration
+// of -1 reveals the stack until Reveal(0) is called.
+func (m *MatrixView) Reveal(duration int) {
+	m.reveal_timer = 0
+	m.reveal_duration = duration
+	m.Updated = true
+}
+
+func (m *MatrixView) Update() {
+	if m.reveal_duration == 0 || m.stack == STACK_VISIBLE {
+		return
+	}
+
+	m.reveal_timer += 1
+	if m.reveal_duration > 0 && m.reveal_timer >= m.reveal_duration {
+		m.reveal_duration = 0
+	}
+	m.Updated = true
+}
+
+// visibility returns how visible the block at pos is, from 0 (invisible) to stack_fade_duration (fully visible).
+func (m *MatrixView) visibility(pos vec.Coord) (visibility int) {
+	switch m.stack {
+	case STACK_VISIBLE:
+		return stack_fade_duration
+	case STACK_FADING:
+		age := m.time - m.board.LockTime(pos)
+		visibility = util.Clamp(m.fade_time+stack_fade_duration-age, 0, stack_fade_duration)
+	}
+
+	if m.reveal_duration != 0 {
+		reveal := m.reveal_timer
+		if m.reveal_duration > 0 {
+			reveal = min(reveal, m.reveal_duration-m.reveal_timer)
+		}
+		visibility = max(visibility, min(reveal, stack_fade_duration))
+	}
+
+	return
 }
 
 func (m *MatrixView) Render() {
-	// render matrix
-	for y, line := range *m.matrix {
-		for x, block := range line.blocks {
-			pos := vec.Coord{x, y}
-			if block != NO_PIECE {
-				glyph := gfx.GLYPH_NONE
-				if y != 0 && (*m.matrix)[y-1].blocks[x] == NO_PIECE {
-					glyph = gfx.GLYPH_HALFBLOCK_UP
-				}
-				drawBlock(&m.Canvas, pos, glyph, pieceData[block].colour, pieceData[block].highlight_colour)
-			} else {
-				m.DrawNone(pos)
-			}
+	// render matrix. blocks might cover more than one cell, so work out which block each cell is showing
+	for cell := range vec.EachCoordInArea(m.Canvas) {
+		pos, ok := m.grid.Block(cell)
+		if !ok || !pos.IsInside(m.board.Size()) {
+			m.DrawNone(cell)
+			continue
+		}
+
+		block := m.board.Block(pos)
+		if block == engine.NO_PIECE {
+			m.DrawNone(cell)
+			continue
+		}
+
+		visibility := m.visibility(pos)
+		if visibility == 0 {
+			m.DrawNone(cell)
+			continue
+		}
+
+		// only the top row of cells in a block gets the highlight
+		glyph := gfx.GLYPH_NONE
+		if cell.Y == m.grid.Cell(pos).Y && pos.Y != 0 && m.board.Block(pos.Step(vec.DIR_UP)) == engine.NO_PIECE {
+			glyph = gfx.GLYPH_HALFBLOCK_UP
+		}
+
+		colour, highlight := m.pieces.Colour(block), m.pieces.Highlight(block)
+		if visibility < stack_fade_duration {
+			colour = col.Lerp(background_colour, colour, visibility, stack_fade_duration)
+			highlight = col.Lerp(background_colour, highlight, visibility, stack_fade_duration)
+		}
+		drawBlock(&m.Canvas, cell, glyph, colour, highlight)
+	}
+}
+
+// Callout is a bit of text that flashes up over the playfield for a moment when the player does something cool.
+type Callout struct {
+	ui.Textbox
+
+	ticks_remaining int
+}
+
+func (c *Callout) Init(size vec.Dims, pos vec.Coord, depth int) {
+	c.Textbox.Init(size, pos, depth, "", true)
+	c.SetDefaultColours(col.Pair{text_colour, background_colour})
+	c.Hide()
+}
+
+// Flash shows the text for the provided number of ticks.
+func (c *Callout) Flash(text string, colour uint32, duration int) {
+	c.ChangeText(text)
+	c.ticks_remaining = duration
+	c.Show()
+
+	pulse := gfx.NewPulseAnimation(c.DrawableArea(), 0, duration/2, col.Pair{colour, col.NONE})
+	pulse.OneShot = true
+	pulse.Start()
+	c.AddAnimation(&pulse)
+}
+
+// Pin shows the text until the callout is hidden.
+func (c *Callout) Pin(text string) {
+	c.ChangeText(text)
+	c.ticks_remaining = 0
+	c.Show()
+}
+
+func (c *Callout) Update() {
+	if c.ticks_remaining > 0 {
+		c.ticks_remaining -= 1
+		if c.ticks_remaining == 0 {
+			c.Hide()
+		}
+	}
+}
+
+// ScoreText shows the player's score. When points are scored for a clear, it pulses and briefly shows how many points
+// were earned.
+type ScoreText struct {
+	ui.Textbox
+
+	score           int
+	ticks_remaining int // ticks left to show the earned points for
+}
+
+func (st *ScoreText) Init(size vec.Dims, pos vec.Coord, depth int) {
+	st.Textbox.Init(size, pos, depth, "0", true)
+}
+
+// SetScore changes the displayed score without any fanfare.
+func (st *ScoreText) SetScore(score int) {
+	st.score = score
+	if st.ticks_remaining == 0 {
+		st.ChangeText(strconv.Itoa(score))
+	}
+}
+
+// AddPoints changes the displayed score, pulsing and showing the points earned.
+func (st *ScoreText) AddPoints(score, points int) {
+	st.score = score
+	st.ticks_remaining = 60
+	st.ChangeText(fmt.Sprintf("%d +%d", score, points))
+
+	pulse := gfx.NewPulseAnimation(st.DrawableArea(), 0, 12, col.Pair{col.YELLOW, col.NONE})
+	pulse.OneShot = true
+	pulse.Start()
+	st.AddAnimation(&pulse)
+}
+
+func (st *ScoreText) Update() {
+	if st.ticks_remaining > 0 {
+		st.ticks_remaining -= 1
+		if st.ticks_remaining == 0 {
+			st.ChangeText(strconv.Itoa(st.score))
 		}
 	}
 }
@@ -73,13 +292,15 @@ func (upv *UpcomingPieceView) Init(size vec.Dims, pos vec.Coord, depth int) {
 	upv.SetupBorder("Upcoming Pieces", "")
 
 	for range 6 {
-		upcoming_piece := PieceElement{}
+		upcoming_piece := PieceElement{preview: true}
 		upcoming_piece.Init(vec.Dims{3, 2}, vec.Coord{0, 0}, 1)
 		upv.AddChild(&upcoming_piece)
 	}
 }
 
-func (upv *UpcomingPieceView) UpdatePieces(pieces []Piece) {
+// UpdatePieces lays out the upcoming pieces from left to right. Pieces come in all sizes, so big ones might mean there
+// isn't room for all of them. Once one doesn't fit, it and the rest after it aren't shown.
+func (upv *UpcomingPieceView) UpdatePieces(pieces []engine.Piece) {
 	piece_elements := upv.GetChildren()
 	for i, piece := range pieces {
 		piece_elements[i].(*PieceElement).UpdatePiece(piece)
@@ -87,38 +308,185 @@ func (upv *UpcomingPieceView) UpdatePieces(pieces []Piece) {
 
 	x := 1
 	for i := range piece_elements {
-		piece_elements[i].MoveTo(vec.Coord{x, 1})
-		x += piece_elements[i].Size().W + 1
+		element := piece_elements[i].(*PieceElement)
+		if x+element.Size().W > upv.Size().W {
+			x = upv.Size().W
+			element.Hide()
+			continue
+		}
+
+		element.MoveTo(vec.Coord{x, (upv.Size().H - element.Size().H + 1) / 2})
+		x += element.Size().W + 1
 	}
 }
 
 func (upv *UpcomingPieceView) Reset() {
 	piece_elements := upv.GetChildren()
 	for i := range piece_elements {
-		piece_elements[i].(*PieceElement).UpdatePiece(Piece{pType: NO_PIECE})
+		piece_elements[i].(*PieceElement).UpdatePiece(engine.Piece{Type: engine.NO_PIECE})
+	}
+}
+
+// GarbageMeter sits beside the playfield and fills up as the next row of garbage gets ready to rise. It flashes when
+// the garbage is about to come up.
+type GarbageMeter struct {
+	ui.Element
+
+	filled  int  // number of cells filled
+	warning bool // whether the warning flash has been played for the coming rise
+}
+
+// ticks before garbage rises that the meter flashes to warn the player
+const garbage_warning_time int = 60
+
+func (gm *GarbageMeter) Init(size vec.Dims, pos vec.Coord, depth int) {
+	gm.Element.Init(size, pos, depth)
+	gm.Hide()
+}
+
+// SetTimer updates the meter with the time since garbage last rose, and how long it takes to rise.
+func (gm *GarbageMeter) SetTimer(ticks, interval int) {
+	filled := 0
+	if interval > 0 {
+		filled = ticks * gm.Size().H / interval
+	}
+
+	if filled != gm.filled {
+		gm.filled = filled
+		gm.Updated = true
+	}
+
+	if interval-ticks > garbage_warning_time {
+		gm.warning = false
+	} else if !gm.warning {
+		gm.warning = true
+		flash := gfx.NewFlashAnimation(gm.DrawableArea(), 1, col.Pair{col.RED, col.RED}, garbage_warning_time/2)
+		flash.OneShot = true
+		flash.Start()
+		gm.AddAnimation(&flash)
+	}
+}
+
+// SetQueued fills the meter with the rows of garbage waiting to rise, for versus games where the garbage comes from
+// the opponent instead of a timer.
+func (gm *GarbageMeter) SetQueued(rows int) {
+	filled := min(rows, gm.Size().H)
+	if filled != gm.filled {
+		gm.filled = filled
+		gm.Updated = true
+	}
+}
+
+func (gm *GarbageMeter) Render() {
+	h := gm.Size().H
+	for y := range h {
+		if h-y <= gm.filled {
+			gm.DrawColours(vec.Coord{0, y}, 0, col.Pair{col.NONE, col.Lerp(col.GREEN, col.RED, h-y, h)})
+		} else {
+			gm.DrawColours(vec.Coord{0, y}, 0, col.Pair{col.NONE, background_colour})
+		}
+	}
+}
+
+// OpponentView shows a small copy of the opponent's board in versus games. Each cell of the view shows two rows of the
+// board, so a whole well fits beside the playfield. Boards too big for the view lose their top rows and edges.
+type OpponentView struct {
+	ui.Element
+
+	rows [][]engine.PieceType
+}
+
+func (ov *OpponentView) Init(size vec.Dims, pos vec.Coord, depth int) {
+	ov.Element.Init(size, pos, depth)
+	ov.EnableBorder()
+	ov.Hide()
+}
+
+// SetOpponent puts the opponent's name on the view and clears their board until they send it.
+func (ov *OpponentView) SetOpponent(name string) {
+	ov.SetupBorder(name, "")
+	ov.rows = nil
+	ov.Updated = true
+}
+
+// SetBoard updates the view with rows of the opponent's board, top row first.
+func (ov *OpponentView) SetBoard(rows []string) {
+	ov.rows = ov.rows[:0]
+	for _, row := range rows {
+		blocks, _ := engine.ParseBoardRow(row)
+		ov.rows = append(ov.rows, blocks)
+	}
+	ov.Updated = true
+}
+
+// block returns the colour of the opponent's board at pos, or the background colour if there's nothing there.
+func (ov *OpponentView) block(pos vec.Coord) uint32 {
+	if pos.Y < 0 || pos.Y >= len(ov.rows) || pos.X < 0 || pos.X >= len(ov.rows[pos.Y]) {
+		return background_colour
+	}
+
+	if block := ov.rows[pos.Y][pos.X]; block != engine.NO_PIECE {
+		return engine.StandardPieces.Colour(block) // custom pieces are sent as garbage
+	}
+
+	return background_colour
+}
+
+func (ov *OpponentView) Render() {
+	size := ov.Size()
+	width := 0
+	if len(ov.rows) > 0 {
+		width = len(ov.rows[0])
+	}
+
+	// line the bottom of the board up with the bottom of the view, centered
+	offset := vec.Coord{(width - size.W) / 2, len(ov.rows) - 2*size.H}
+	for cell := range vec.EachCoordInArea(ov.Canvas) {
+		top := vec.Coord{cell.X + offset.X, 2*cell.Y + offset.Y}
+		bottom := top.Step(vec.DIR_DOWN)
+		ov.DrawVisuals(cell, 0, gfx.NewGlyphVisuals(gfx.GLYPH_HALFBLOCK_UP, col.Pair{ov.block(top), ov.block(bottom)}))
 	}
 }
 
 type HighScoreView struct {
 	ui.Element
 
-	scores ui.Textbox
+	heading   string
+	mode_name ui.Textbox
+	scores    ui.Textbox
 }
 
 func (hsv *HighScoreView) Init(size vec.Dims, pos vec.Coord, depth int) {
 	hsv.Element.Init(size, pos, depth)
 
+	hsv.mode_name.Init(vec.Dims{size.W, 1}, vec.Coord{0, 1}, 1, "", true)
+	hsv.mode_name.SetDefaultColours(col.Pair{text_colour, background_colour})
+	hsv.AddChild(&hsv.mode_name)
+
 	hsv.scores.Init(vec.Dims{size.W - 4, size.H - 2}, vec.Coord{2, 2}, 1, "some scores", false)
 	hsv.scores.SetDefaultColours(col.Pair{text_colour, background_colour})
 	hsv.AddChild(&hsv.scores)
 }
 
-func (hsv *HighScoreView) UpdateScores(hs HighScores) {
+// UpdateScores shows the leaderboard for the provided mode.
+func (hsv *HighScoreView) UpdateScores(hs HighScores, mode Mode) {
 	scoreText := ""
 
-	for i, entry := range hs.Scores {
-		scoreText += fmt.Sprintf("%2d) %-5s %6d/n", i+1, entry.Name, entry.Score)
+	for i, entry := range hs.Board(mode) {
+		if mode.RankedByTime() {
+			scoreText += fmt.Sprintf("%2d %-5s %7s/n", i+1, entry.Name, formatTime(entry.Time, 2))
+		} else {
+			scoreText += fmt.Sprintf("%2d) %-5s %6d/n", i+1, entry.Name, entry.Score)
+		}
+	}
+
+	if mode.RankedByTime() {
+		hsv.heading = "BEST TIMES!"
+	} else {
+		hsv.heading = "HIGH SCORES!"
 	}
+	hsv.mode_name.ChangeText(mode.Name())
+	hsv.Updated = true
 
 	if scoreText != "" {
 		hsv.scores.ChangeText(scoreText)
@@ -127,6 +495,19 @@ func (hsv *HighScoreView) UpdateScores(hs HighScores) {
 	}
 }
 
+// ShowPuzzle shows the puzzle's objective instead of a leaderboard, since puzzles don't have one.
+func (hsv *HighScoreView) ShowPuzzle(puzzle Puzzle) {
+	hsv.heading = "OBJECTIVE!"
+	hsv.mode_name.ChangeText(puzzle.Name)
+	hsv.Updated = true
+
+	text := "/n" + puzzle.ObjectiveText()
+	if puzzle.Hint != "" {
+		text += "/n/n" + puzzle.Hint
+	}
+	hsv.scores.ChangeText(text)
+}
+
 func (hsv *HighScoreView) Render() {
-	hsv.DrawFullWidthText(vec.Coord{0, 0}, 0, "HIGH SCORES!", col.Pair{text_colour, background_colour})
+	hsv.DrawFullWidthText(vec.Coord{0, 0}, 0, hsv.heading, col.Pair{text_colour, background_colour})
 }
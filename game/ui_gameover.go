@@ -2,7 +2,9 @@ package main
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/bennicholls/tytris/engine"
 	"github.com/bennicholls/tyumi/gfx"
 	"github.com/bennicholls/tyumi/gfx/col"
 	"github.com/bennicholls/tyumi/gfx/ui"
@@ -10,23 +12,60 @@ import (
 	"github.com/bennicholls/tyumi/vec"
 )
 
+// Result describes how a game went, for the game over screen.
+type Result struct {
+	Mode      Mode
+	Info      engine.Info
+	Ending    engine.Ending // ENDING_NONE if the player gave up
+	Cleared   bool          // whether the mode's goal was reached. the game can go on past it, so check this too
+	Replay    bool
+	HighScore bool           // whether the result earned a place on the mode's leaderboard
+	Best      HighScoreEntry // the best result on the mode's leaderboard before this game, if there was one
+	Next      *Mode          // the next puzzle in the pack, if a puzzle was solved and there are more to go
+	Grade     string         // for MODE_MASTER
+	Opponent  string         // name of the opponent, for MODE_VERSUS
+	Won       bool           // the opponent topped out first, for MODE_VERSUS
+	Forfeit   bool           // the opponent left before the game was decided, for MODE_VERSUS
+	Winner    string         // name of the player who won a local MODE_VERSUS game, if anybody did
+}
+
+// Entry returns the leaderboard entry for the result (minus the name, which the player hasn't entered yet). Timed
+// modes only record a time if the goal was reached.
+func (r Result) Entry() (entry HighScoreEntry) {
+	entry.Score = r.Info.Score
+	if r.Mode.RankedByTime() && r.Ending == engine.ENDING_GOAL {
+		entry.Time = r.Info.Time
+	}
+
+	return
+}
+
 type GameOverScreen struct {
 	ui.Element
 
+	image      ui.Image   // the big GAME OVER, for when the player was defeated
+	title      ui.Textbox // shown instead of the image when the game ended some other way
 	statsBox   ui.Textbox
 	message    ui.Textbox
 	name_input ui.InputBox
 
-	info GameInfo
+	result Result
 }
 
 func (gos *GameOverScreen) Init(size vec.Dims, pos vec.Coord, depth int) {
 	gos.Element.Init(size, pos, depth)
 	gos.EnableBorder()
 
-	gameOverImage := ui.Image{}
-	gameOverImage.Init(vec.Coord{2, 1}, 0, "res/gameover.xp")
-	gos.AddChild(&gameOverImage)
+	gos.image.Init(vec.Coord{2, 1}, 0, "res/gameover.xp")
+	gos.AddChild(&gos.image)
+
+	gos.title.Init(vec.Dims{size.W - 11, 1}, vec.Coord{0, 4}, 0, "", true)
+	gos.title.SetDefaultColours(col.Pair{text_colour, background_colour})
+	pulse := gfx.NewPulseAnimation(gos.title.DrawableArea(), 0, 60, col.Pair{col.GREEN, col.NONE})
+	pulse.Repeat = true
+	pulse.Start()
+	gos.title.AddAnimation(&pulse)
+	gos.AddChild(&gos.title)
 
 	gos.message.Init(vec.Dims{size.W - 11, 3}, vec.Coord{0, 9}, 0, "", true)
 	gos.message.SetDefaultColours(col.Pair{text_colour, background_colour})
@@ -47,7 +86,9 @@ func (gos *GameOverScreen) Init(size vec.Dims, pos vec.Coord, depth int) {
 	gos.Hide()
 }
 
-func (gos *GameOverScreen) Activate(info GameInfo) {
+func (gos *GameOverScreen) Activate(result Result) {
+	info := result.Info
+
 	flash := gfx.NewFlashAnimation(gos.Canvas.Bounds(), ui.BorderDepth+1, col.Pair{col.FUSCHIA, col.FUSCHIA}, 30)
 	flash.OneShot = true
 	flash.Blocking = true
@@ -56,6 +97,7 @@ func (gos *GameOverScreen) Activate(info GameInfo) {
 
 	stats := fmt.Sprintf(`
 		Score         %6d/n
+		Level         %6d/n
 		Total Time    %6d/n
 		Pieces        %6d/n
 		Quick Drops   %6d/n
@@ -63,22 +105,172 @@ func (gos *GameOverScreen) Activate(info GameInfo) {
 		Lines Cleared %6d/n
 		Double Kills  %6d/n
 		Triple Kills  %6d/n
-		QUAD Kills    %6d/n`, info.score, info.time/60, info.pieces_dropped, info.quick_drops, info.swaps, info.lines_destroyed, info.double_kills, info.triple_kills, info.quad_kills)
+		QUAD Kills    %6d/n
+		T-Spins       %6d/n
+		T-Spin Minis  %6d/n
+		Max Combo     %6d/n
+		Back-to-Backs %6d/n
+		Seed     %11d/n`, info.Score, info.Level, info.Time/60, info.PiecesDropped, info.QuickDrops, info.Swaps, info.LinesDestroyed, info.DoubleKills, info.TripleKills, info.QuadKills, info.TSpins, info.TSpinMinis, info.MaxCombo, info.BackToBacks, info.Seed)
+
+	if result.Mode.Type == MODE_DIG {
+		pieces_per_line := 0.0
+		if info.GarbageCleared > 0 {
+			pieces_per_line = float64(info.PiecesDropped) / float64(info.GarbageCleared)
+		}
+
+		stats += fmt.Sprintf(`/n
+		Garbage Lines %6d/n
+		Pieces/Line   %6.2f/n`, info.GarbageCleared, pieces_per_line)
+	}
+
+	if result.Mode.Type == MODE_VERSUS {
+		stats += fmt.Sprintf(`/n
+		Garbage Sent  %6d/n
+		Garbage Taken %6d/n`, info.GarbageSent, info.GarbageReceived)
+	}
 
 	gos.statsBox.ChangeText(stats)
 
-	if info.high_score {
-		gos.message.ChangeText("Huzzah, you got a highscore! Enter your name and be remembered for eternity!")
+	if title := resultTitle(result); title != "" {
+		gos.title.ChangeText(title)
+		gos.title.Show()
+		gos.image.Hide()
+	} else {
+		gos.title.Hide()
+		gos.image.Show()
+	}
+
+	gos.message.ChangeText(resultMessage(result))
+	if result.HighScore {
 		gos.name_input.Show()
 	} else {
-		gos.message.ChangeText("The endless torrent of menacing colours and shapes has defeated you. The poets will sing of your demise.")
 		gos.name_input.Hide()
 	}
 
-	gos.info = info
+	gos.result = result
 	gos.Show()
 }
 
+// resultTitle returns the heading for the results screen, or "" if the player was defeated and gets the big GAME OVER
+// instead.
+func resultTitle(result Result) string {
+	switch {
+	case result.Ending == engine.ENDING_TIME_UP:
+		return "T I M E   U P !"
+	case result.Mode.Type == MODE_MASTER:
+		return "G R A D E   " + strings.Join(strings.Split(result.Grade, ""), " ")
+	case result.Winner != "":
+		return strings.Join(strings.Split(strings.ToUpper(result.Winner), ""), " ") + "   W I N S !"
+	case result.Mode.Type == MODE_VERSUS && result.Won:
+		return "Y O U   W I N !"
+	case result.Mode.Type == MODE_PUZZLE && result.Cleared:
+		return "S O L V E D !"
+	case result.Ending == engine.ENDING_OUT_OF_PIECES:
+		return "O U T   O F   P I E C E S"
+	case result.Mode.Type == MODE_SPRINT && result.Cleared:
+		return "F I N I S H E D !"
+	case result.Cleared:
+		return "C L E A R E D !"
+	default:
+		return ""
+	}
+}
+
+// resultMessage describes the result to the player.
+func resultMessage(result Result) (message string) {
+	if result.Replay { // replays can't get high scores, it wouldn't be fair
+		return "That's the end of the replay. Press any key to return to the menu."
+	}
+
+	if result.Mode.Local {
+		if result.Winner == "" {
+			return "Nobody's left standing, so nobody wins. Rematch?"
+		}
+		return fmt.Sprintf("%s wins, after sending %d lines of garbage over to %s!", result.Winner, result.Info.GarbageSent, result.Opponent)
+	}
+
+	if result.Mode.Type == MODE_VERSUS {
+		if result.Forfeit {
+			return fmt.Sprintf("%s left the game, so the win is yours by default.", result.Opponent)
+		} else if result.Won {
+			return fmt.Sprintf("You buried %s under %d lines of garbage. Victory!", result.Opponent, result.Info.GarbageSent)
+		}
+		return fmt.Sprintf("%s got the better of you this time. Better luck in the rematch!", result.Opponent)
+	}
+
+	if result.Mode.Type == MODE_PUZZLE {
+		if !result.Cleared {
+			return "So close! [R] to try again, [Esc] to go back to the menu."
+		} else if result.Next != nil {
+			return "Puzzle solved! [Enter] for the next one, [R] to retry, [Esc] for the menu."
+		}
+		return "Puzzle solved, and that's the whole pack done! [R] to retry, [Esc] for the menu."
+	}
+
+	if result.Mode.RankedByTime() && result.Ending == engine.ENDING_GOAL {
+		if result.Mode.Type == MODE_DIG {
+			message = "Dug out in " + formatTime(result.Info.Time, 3) + "!"
+		} else {
+			message = "Sprint complete in " + formatTime(result.Info.Time, 3) + "!"
+		}
+		if result.Best.Time > 0 {
+			message += " That's " + formatTimeDelta(result.Info.Time-result.Best.Time) + " against your best."
+		}
+		if result.HighScore {
+			message += " Enter your name!"
+		}
+		return
+	}
+
+	if result.Mode.Type == MODE_MARATHON && result.Cleared {
+		if result.Ending == engine.ENDING_GOAL {
+			message = fmt.Sprintf("Marathon cleared! %d points in %s.", result.Info.Score, formatTime(result.Info.Time, 0))
+		} else {
+			message = fmt.Sprintf("You cleared the marathon and kept on going, %d lines in all. %d points!", result.Info.LinesDestroyed, result.Info.Score)
+		}
+		if result.HighScore {
+			message += " That's a highscore, enter your name!"
+		}
+		return
+	}
+
+	if result.Mode.Type == MODE_MASTER {
+		if result.Grade == "GM" {
+			message = fmt.Sprintf("Level %d in %s. You are a GRAND MASTER!", result.Info.Level, formatTime(result.Info.Time, 2))
+		} else if result.Cleared {
+			message = fmt.Sprintf("You made it to level %d in %s, earning grade %s.", result.Info.Level, formatTime(result.Info.Time, 2), result.Grade)
+		} else {
+			message = fmt.Sprintf("You reached level %d before the speed got you, earning grade %s.", result.Info.Level, result.Grade)
+		}
+		if result.HighScore {
+			message += " Enter your name!"
+		}
+		return
+	}
+
+	if result.Mode.Type == MODE_SURVIVAL {
+		message = fmt.Sprintf("You survived for %s before the garbage got the better of you. %d points!", formatTime(result.Info.Time, 1), result.Info.Score)
+		if result.HighScore {
+			message += " Enter your name!"
+		}
+		return
+	}
+
+	if result.Ending == engine.ENDING_TIME_UP {
+		message = fmt.Sprintf("Time's up! You scored %d points in %s.", result.Info.Score, formatTime(result.Info.Time, 0))
+		if result.HighScore {
+			message += " That's a highscore, enter your name!"
+		}
+		return
+	}
+
+	if result.HighScore {
+		return "Huzzah, you got a highscore! Enter your name and be remembered for eternity!"
+	}
+
+	return "The endless torrent of menacing colours and shapes has defeated you. The poets will sing of your demise."
+}
+
 func (gos *GameOverScreen) HandleKeypress(key_event *input.KeyboardEvent) (event_handled bool) {
 	if key_event.PressType == input.KEY_RELEASED {
 		return
@@ -86,11 +278,31 @@ func (gos *GameOverScreen) HandleKeypress(key_event *input.KeyboardEvent) (event
 
 	if gos.name_input.IsVisible() {
 		if key_event.Key == input.K_RETURN {
-			fireHighScoreEvent(gos.name_input.InputtedText(), gos.info.score)
+			entry := gos.result.Entry()
+			entry.Name = gos.name_input.InputtedText()
+			fireHighScoreEvent(gos.result.Mode, entry)
 			gos.Hide()
 			fireStateChangeEvent(GAME_START)
 			event_handled = true
 		}
+	} else if gos.result.Mode.Type == MODE_PUZZLE && !gos.result.Replay {
+		switch key_event.Key {
+		case input.K_r:
+			gos.Hide()
+			fireNewGameEvent(gos.result.Mode)
+		case input.K_RETURN:
+			if gos.result.Next == nil {
+				return
+			}
+			gos.Hide()
+			fireNewGameEvent(*gos.result.Next)
+		case input.K_ESCAPE:
+			gos.Hide()
+			fireStateChangeEvent(GAME_START)
+		default:
+			return
+		}
+		event_handled = true
 	} else { // no high score being entered, back to menu on any keypress
 		gos.Hide()
 		fireStateChangeEvent(GAME_START)
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"slices"
+	"strings"
+
 	"github.com/bennicholls/tyumi"
 	"github.com/bennicholls/tyumi/event"
 	"github.com/bennicholls/tyumi/gfx"
 	"github.com/bennicholls/tyumi/gfx/col"
 	"github.com/bennicholls/tyumi/gfx/ui"
 	"github.com/bennicholls/tyumi/input"
+	"github.com/bennicholls/tyumi/log"
 	"github.com/bennicholls/tyumi/vec"
 )
 
@@ -17,11 +21,37 @@ type MainMenu struct {
 	pause_menu    ui.List
 	pause_message ui.Textbox
 
+	mode_menu    ui.List
+	options_menu ui.List
+	options      []Mode        // the modes in the options menu
+	stack        StackModifier // chosen with left and right in the mode and options menus
+	big          bool          // toggled with tab in the mode and options menus
+	watch        bool          // the computer plays the chosen mode, picked with Watch AI
+
+	bot_menu ui.List
+	bots     []string // names of the external bots in the bot menu, after the built-in AI
+	bot      string   // the bot chosen to play as the computer. empty for the built-in AI
+
+	puzzle_menu ui.List
+	puzzle_pack string // name of the pack shown in the puzzle menu
+	well_width  int    // puzzles with rows wider than this can't be played
+
+	marathon_goal int // line goal from the settings, offered along with the usual marathon goals
+
+	replay_menu  ui.List
+	replay_help  ui.Textbox
+	replay_names []string // names of the replays in the replay menu
+
+	versus_menu    ui.List
+	address_input  ui.InputBox // address of the game to join
+	versus_status  ui.Textbox
+	versus_waiting bool // hosting or joining a game, waiting for it to start
+
 	about_text ui.Textbox
 }
 
-func (mm *MainMenu) Init(size vec.Dims) {
-	mm.GridArea.Init(size, vec.ZERO_COORD, ui.BorderDepth)
+func (mm *MainMenu) Init(size vec.Dims, pos vec.Coord, depth int) {
+	mm.GridArea.Init(size, pos, depth)
 	mm.SetLabel("menu")
 	mm.SetDefaultVisuals(gfx.Visuals{
 		Mode:    gfx.DRAW_GLYPH,
@@ -37,12 +67,15 @@ func (mm *MainMenu) Init(size vec.Dims) {
 	mm.pause_message.AddAnimation(&pulse)
 	mm.AddChild(&mm.pause_message)
 
-	mm.new_game_menu.Init(vec.Dims{6, 5}, vec.Coord{2, 5}, 1)
+	mm.new_game_menu.Init(vec.Dims{6, 11}, vec.Coord{2, 3}, 1)
 	mm.new_game_menu.ToggleHighlight()
 	mm.new_game_menu.SetPadding(1)
 	mm.new_game_menu.EnableBorder()
 	mm.new_game_menu.AddChildren(
 		ui.NewTextbox(vec.Dims{6, 1}, vec.ZERO_COORD, 1, "New Game", true),
+		ui.NewTextbox(vec.Dims{6, 1}, vec.ZERO_COORD, 1, "Versus", true),
+		ui.NewTextbox(vec.Dims{6, 1}, vec.ZERO_COORD, 1, "Watch AI", true),
+		ui.NewTextbox(vec.Dims{6, 1}, vec.ZERO_COORD, 1, "Replays", true),
 		ui.NewTextbox(vec.Dims{6, 1}, vec.ZERO_COORD, 1, "About", true),
 		ui.NewTextbox(vec.Dims{6, 1}, vec.ZERO_COORD, 1, "Quit", true),
 	)
@@ -63,7 +96,74 @@ func (mm *MainMenu) Init(size vec.Dims) {
 		sounds.Play("move")
 	}
 
-	mm.AddChildren(&mm.new_game_menu, &mm.pause_menu)
+	mm.mode_menu.Init(vec.Dims{6, len(game_modes)}, vec.Coord{2, 5}, 1)
+	mm.mode_menu.ToggleHighlight()
+	mm.mode_menu.SetupBorder("Mode", "")
+	for _, mode := range game_modes {
+		mm.mode_menu.AddChild(ui.NewTextbox(vec.Dims{6, 1}, vec.ZERO_COORD, 1, mode.String(), true))
+	}
+	mm.mode_menu.OnChangeSelection = func() {
+		sounds.Play("move")
+	}
+
+	mm.options_menu.Init(vec.Dims{6, 5}, vec.Coord{2, 5}, 1)
+	mm.options_menu.ToggleHighlight()
+	mm.options_menu.SetPadding(1)
+	mm.updateModifiers()
+	mm.options_menu.OnChangeSelection = func() {
+		sounds.Play("move")
+	}
+
+	mm.puzzle_menu.Init(vec.Dims{8, 9}, vec.Coord{1, 4}, 1)
+	mm.puzzle_menu.ToggleHighlight()
+	mm.puzzle_menu.OnChangeSelection = func() {
+		sounds.Play("move")
+	}
+
+	mm.replay_menu.Init(vec.Dims{8, 6}, vec.Coord{1, 4}, 1)
+	mm.replay_menu.ToggleHighlight()
+	mm.replay_menu.SetupBorder("Replays", "[Esc]")
+	mm.replay_menu.OnChangeSelection = func() {
+		sounds.Play("move")
+	}
+
+	mm.versus_menu.Init(vec.Dims{6, 7}, vec.Coord{2, 2}, 1)
+	mm.versus_menu.ToggleHighlight()
+	mm.versus_menu.SetPadding(1)
+	mm.versus_menu.SetupBorder("Versus", "[Esc]")
+	mm.versus_menu.AddChildren(
+		ui.NewTextbox(vec.Dims{6, 1}, vec.ZERO_COORD, 1, "Local Game", true),
+		ui.NewTextbox(vec.Dims{6, 1}, vec.ZERO_COORD, 1, "Versus AI", true),
+		ui.NewTextbox(vec.Dims{6, 1}, vec.ZERO_COORD, 1, "Host Game", true),
+		ui.NewTextbox(vec.Dims{6, 1}, vec.ZERO_COORD, 1, "Join Game", true),
+	)
+	mm.versus_menu.OnChangeSelection = func() {
+		sounds.Play("move")
+	}
+
+	mm.bot_menu.Init(vec.Dims{6, 1}, vec.Coord{2, 5}, 1)
+	mm.bot_menu.ToggleHighlight()
+	mm.bot_menu.SetPadding(1)
+	mm.bot_menu.SetupBorder("Computer", "[Esc]")
+	mm.SetBots(nil)
+	mm.bot_menu.OnChangeSelection = func() {
+		sounds.Play("move")
+	}
+
+	mm.address_input.Init(vec.Dims{size.W - 2, 1}, vec.Coord{1, 4}, 1, (size.W-2)*2)
+	mm.address_input.SetDefaultColours(col.Pair{background_colour, border_colour})
+	mm.address_input.SetupBorder("Address", "[Enter]")
+
+	mm.versus_status.Init(vec.Dims{size.W - 2, 3}, vec.Coord{1, 11}, 1, "", true)
+	mm.versus_status.SetDefaultColours(col.Pair{text_colour, background_colour})
+	mm.versus_status.EnableBorder()
+
+	mm.replay_help.Init(vec.Dims{8, 3}, vec.Coord{1, 11}, 1, "Speed    Arrows/nPause     Space/nStep     Period", true)
+	mm.replay_help.SetDefaultColours(col.Pair{text_colour, background_colour})
+	mm.replay_help.EnableBorder()
+
+	mm.AddChildren(&mm.new_game_menu, &mm.pause_menu, &mm.mode_menu, &mm.options_menu, &mm.puzzle_menu, &mm.replay_menu, &mm.replay_help)
+	mm.AddChildren(&mm.versus_menu, &mm.address_input, &mm.versus_status, &mm.bot_menu)
 
 	mm.about_text.Init(vec.Dims{size.W - 2, ui.FIT_TEXT}, vec.Coord{1, 5}, 2, "TYTRIS/n/nCreated by/nBEN NICHOLLS/n/nPlease do not sue me for this thanks", true)
 	mm.about_text.SetDefaultColours(col.Pair{text_colour, background_colour})
@@ -72,7 +172,7 @@ func (mm *MainMenu) Init(size vec.Dims) {
 	mm.AddChild(&mm.about_text)
 
 	controls := ControlsView{}
-	controls.Init(vec.Dims{size.W, 9}, vec.Coord{0, 16}, 0)
+	controls.Init(vec.Dims{size.W, 10}, vec.Coord{0, 15}, 0)
 	mm.AddChild(&controls)
 
 	mm.Activate(GAME_START)
@@ -83,6 +183,12 @@ func (mm *MainMenu) Activate(state int) {
 	case GAME_START:
 		mm.pause_message.Hide()
 		mm.pause_menu.Hide()
+		mm.mode_menu.Hide()
+		mm.options_menu.Hide()
+		mm.puzzle_menu.Hide()
+		mm.bot_menu.Hide()
+		mm.hideReplays()
+		mm.hideVersus()
 		mm.new_game_menu.Show()
 	case PAUSED:
 		mm.pause_message.Show()
@@ -102,24 +208,163 @@ func (mm *MainMenu) HandleKeypress(key_event *input.KeyboardEvent) (event_handle
 	}
 
 	switch key_event.Key {
+	case input.K_LEFT, input.K_RIGHT:
+		if mm.mode_menu.IsVisible() || mm.options_menu.IsVisible() {
+			mm.cycleStack(key_event.Key == input.K_RIGHT)
+			event_handled = true
+		}
+	case input.K_TAB:
+		if mm.mode_menu.IsVisible() || mm.options_menu.IsVisible() {
+			mm.big = !mm.big
+			mm.updateModifiers()
+			sounds.Play("move")
+			event_handled = true
+		}
+	case input.K_ESCAPE:
+		if mm.versus_waiting {
+			fireVersusEvent(VERSUS_CANCEL, "")
+			mm.SetVersusStatus("Cancelled.", false)
+			event_handled = true
+		} else if mm.address_input.IsVisible() {
+			mm.address_input.Hide()
+			mm.versus_menu.Show()
+			event_handled = true
+		} else if mm.bot_menu.IsVisible() {
+			mm.bot_menu.Hide()
+			if mm.watch {
+				mm.new_game_menu.Show()
+			} else {
+				mm.versus_menu.Show()
+			}
+			event_handled = true
+		} else if mm.versus_menu.IsVisible() {
+			mm.hideVersus()
+			mm.new_game_menu.Show()
+			event_handled = true
+		} else if mm.replay_menu.IsVisible() {
+			mm.hideReplays()
+			mm.new_game_menu.Show()
+			event_handled = true
+		} else if mm.puzzle_menu.IsVisible() {
+			mm.puzzle_menu.Hide()
+			mm.options_menu.Show()
+			event_handled = true
+		} else if mm.options_menu.IsVisible() {
+			mm.options_menu.Hide()
+			mm.mode_menu.Show()
+			event_handled = true
+		} else if mm.mode_menu.IsVisible() {
+			mm.mode_menu.Hide()
+			mm.new_game_menu.Show()
+			event_handled = true
+		}
 	case input.K_RETURN:
 		if mm.about_text.IsVisible() {
 			mm.about_text.Hide()
 			event_handled = true
 		} else if mm.new_game_menu.IsVisible() {
 			switch mm.new_game_menu.GetSelectionIndex() {
-			case 0: // New Game
-				fireStateChangeEvent(NEW_GAME)
+			case 0, 2: // New Game, Watch AI
+				mm.watch = mm.new_game_menu.GetSelectionIndex() == 2
+				mm.bot = ""
+				mm.updateModifiers()
+				mm.new_game_menu.Hide()
+				if mm.watch && len(mm.bots) > 0 {
+					mm.showBots()
+				} else {
+					mm.mode_menu.Show()
+				}
+				sounds.Play("enter")
+				event_handled = true
+			case 1: // Versus
+				mm.showVersus()
+				sounds.Play("enter")
+				event_handled = true
+			case 3: // Replays
+				mm.showReplays()
 				sounds.Play("enter")
 				event_handled = true
-			case 1: // About
+			case 4: // About
 				mm.about_text.Show()
 				sounds.Play("enter")
 				event_handled = true
-			case 2: //quit
+			case 5: //quit
 				event.Fire(event.New(tyumi.EV_QUIT))
 				event_handled = true
 			}
+		} else if mm.bot_menu.IsVisible() {
+			mm.bot = ""
+			if i := mm.bot_menu.GetSelectionIndex(); i > 0 {
+				mm.bot = mm.bots[i-1]
+			}
+			mm.bot_menu.Hide()
+			if mm.watch {
+				mm.mode_menu.Show()
+			} else {
+				fireNewGameEvent(Mode{Type: MODE_VERSUS, Local: true, AI: true, Bot: mm.bot})
+			}
+			sounds.Play("enter")
+			event_handled = true
+		} else if mm.versus_waiting {
+			event_handled = true // nothing to do but wait
+		} else if mm.address_input.IsVisible() {
+			if address := mm.address_input.InputtedText(); address != "" {
+				fireVersusEvent(VERSUS_JOIN, address)
+				sounds.Play("enter")
+			}
+			event_handled = true
+		} else if mm.versus_menu.IsVisible() {
+			switch mm.versus_menu.GetSelectionIndex() {
+			case 0: // Local Game
+				fireNewGameEvent(Mode{Type: MODE_VERSUS, Local: true})
+			case 1: // Versus AI
+				mm.watch = false
+				if len(mm.bots) > 0 {
+					mm.versus_menu.Hide()
+					mm.showBots()
+				} else {
+					fireNewGameEvent(Mode{Type: MODE_VERSUS, Local: true, AI: true})
+				}
+			case 2: // Host Game
+				fireVersusEvent(VERSUS_HOST, "")
+			case 3: // Join Game
+				mm.versus_menu.Hide()
+				mm.address_input.Show()
+				mm.SetVersusStatus("Enter the host's address, like 192.168.0.2", false)
+			}
+			sounds.Play("enter")
+			event_handled = true
+		} else if mm.mode_menu.IsVisible() {
+			mode := game_modes[mm.mode_menu.GetSelectionIndex()]
+			options := mode.Options(mm.marathon_goal)
+			if len(options) == 0 {
+				log.Info("No options for mode ", mode, ", are there any puzzles in the puzzle directory?")
+			} else if len(options) > 1 || mode == MODE_PUZZLE {
+				mm.showOptions(options)
+			} else {
+				mm.startGame(options[0])
+			}
+			sounds.Play("enter")
+			event_handled = true
+		} else if mm.options_menu.IsVisible() {
+			option := mm.options[mm.options_menu.GetSelectionIndex()]
+			if option.Type == MODE_PUZZLE {
+				mm.showPuzzles(option.Pack)
+			} else {
+				mm.startGame(option)
+			}
+			sounds.Play("enter")
+			event_handled = true
+		} else if mm.puzzle_menu.IsVisible() {
+			mm.startGame(Mode{Type: MODE_PUZZLE, Pack: mm.puzzle_pack, Puzzle: mm.puzzle_menu.GetSelectionIndex()})
+			sounds.Play("enter")
+			event_handled = true
+		} else if mm.replay_menu.IsVisible() {
+			if len(mm.replay_names) > 0 {
+				firePlayReplayEvent(mm.replay_names[mm.replay_menu.GetSelectionIndex()])
+				sounds.Play("enter")
+			}
+			event_handled = true
 		} else if mm.pause_menu.IsVisible() {
 			switch mm.pause_menu.GetSelectionIndex() {
 			case 0: // Resume
@@ -139,6 +384,171 @@ func (mm *MainMenu) HandleKeypress(key_event *input.KeyboardEvent) (event_handle
 	return
 }
 
+// startGame starts a new game in the chosen mode, with the chosen modifiers.
+func (mm *MainMenu) startGame(mode Mode) {
+	mode.Stack = mm.stack
+	mode.Big = mm.big
+	mode.AI = mm.watch
+	if mm.watch {
+		mode.Bot = mm.bot
+	}
+	fireNewGameEvent(mode)
+}
+
+// cycleStack switches to the next (or previous) stack modifier.
+func (mm *MainMenu) cycleStack(forward bool) {
+	i := slices.Index(stack_modifiers, mm.stack)
+	if forward {
+		i = (i + 1) % len(stack_modifiers)
+	} else {
+		i = (i + len(stack_modifiers) - 1) % len(stack_modifiers)
+	}
+
+	mm.stack = stack_modifiers[i]
+	mm.updateModifiers()
+	sounds.Play("move")
+}
+
+// updateModifiers shows the chosen modifiers on the borders of the mode and options menus. The stack modifier goes at
+// the bottom, and big mode and watching the AI are shown in the titles.
+func (mm *MainMenu) updateModifiers() {
+	hint := "<" + mm.stack.String() + ">"
+	title := ""
+	if mm.watch {
+		title = "AI "
+	}
+
+	if mm.big {
+		mm.mode_menu.SetupBorder(title+"Big Mode", hint)
+		mm.options_menu.SetupBorder(title+"Big", hint)
+	} else {
+		mm.mode_menu.SetupBorder(title+"Mode", hint)
+		mm.options_menu.SetupBorder(strings.TrimSpace(title), hint)
+	}
+}
+
+// SetBots fills the bot menu with the built-in AI and the external bots that can be picked to play as the computer.
+func (mm *MainMenu) SetBots(names []string) {
+	for _, item := range slices.Clone(mm.bot_menu.GetChildren()) {
+		mm.bot_menu.RemoveChild(item)
+	}
+
+	mm.bots = names
+	mm.bot_menu.AddChild(ui.NewTextbox(vec.Dims{6, 1}, vec.ZERO_COORD, 1, "Built-in", true))
+	for _, name := range names {
+		mm.bot_menu.AddChild(ui.NewTextbox(vec.Dims{6, 1}, vec.ZERO_COORD, 1, name, true))
+	}
+
+	mm.bot_menu.Resize(vec.Dims{6, 2*len(names) + 1})
+}
+
+// showBots shows the bot menu, so the player can choose who plays as the computer. It's only shown if there are
+// external bots to choose from.
+func (mm *MainMenu) showBots() {
+	mm.bot_menu.Select(0)
+	mm.bot_menu.Show()
+}
+
+// showOptions fills the options menu with the variations of a mode, and shows it in place of the mode menu.
+func (mm *MainMenu) showOptions(options []Mode) {
+	for _, item := range slices.Clone(mm.options_menu.GetChildren()) {
+		mm.options_menu.RemoveChild(item)
+	}
+
+	mm.options = options
+	for _, option := range options {
+		mm.options_menu.AddChild(ui.NewTextbox(vec.Dims{6, 1}, vec.ZERO_COORD, 1, option.OptionName(), true))
+	}
+
+	mm.options_menu.Resize(vec.Dims{6, 2*len(options) - 1})
+	mm.options_menu.Select(0)
+	mm.mode_menu.Hide()
+	mm.options_menu.Show()
+}
+
+// showPuzzles fills the puzzle menu with the puzzles from a pack, marking the ones that have been solved, and shows it
+// in place of the options menu.
+func (mm *MainMenu) showPuzzles(pack_name string) {
+	pack, err := loadPuzzlePack(pack_name, mm.well_width)
+	if err != nil {
+		log.Error("Could not load puzzle pack ", pack_name, ": ", err)
+		return
+	}
+
+	for _, item := range slices.Clone(mm.puzzle_menu.GetChildren()) {
+		mm.puzzle_menu.RemoveChild(item)
+	}
+
+	progress := loadPuzzleProgress()
+	for _, puzzle := range pack.Puzzles {
+		name := "  " + puzzle.Name
+		if progress.IsSolved(pack_name, puzzle.Name) {
+			name = "* " + puzzle.Name
+		}
+		mm.puzzle_menu.AddChild(ui.NewTextbox(vec.Dims{8, 1}, vec.ZERO_COORD, 1, name, false))
+	}
+
+	mm.puzzle_pack = pack_name
+	mm.puzzle_menu.SetupBorder(pack.Title, "[Esc]")
+	mm.puzzle_menu.Select(0)
+	mm.options_menu.Hide()
+	mm.puzzle_menu.Show()
+}
+
+// showReplays fills the replay menu with the saved replays, newest first, and shows it in place of the new game menu.
+func (mm *MainMenu) showReplays() {
+	for _, item := range slices.Clone(mm.replay_menu.GetChildren()) {
+		mm.replay_menu.RemoveChild(item)
+	}
+
+	mm.replay_names = listReplays()
+	for _, name := range mm.replay_names {
+		mm.replay_menu.AddChild(ui.NewTextbox(vec.Dims{8, 1}, vec.ZERO_COORD, 1, replayTitle(name), true))
+	}
+
+	if len(mm.replay_names) == 0 {
+		mm.replay_menu.AddChild(ui.NewTextbox(vec.Dims{8, 1}, vec.ZERO_COORD, 1, "No replays yet!", true))
+	}
+
+	mm.replay_menu.Select(0)
+	mm.new_game_menu.Hide()
+	mm.replay_menu.Show()
+	mm.replay_help.Show()
+}
+
+func (mm *MainMenu) hideReplays() {
+	mm.replay_menu.Hide()
+	mm.replay_help.Hide()
+}
+
+// showVersus shows the versus menu in place of the new game menu.
+func (mm *MainMenu) showVersus() {
+	mm.versus_menu.Select(0)
+	mm.SetVersusStatus("Take on a friend, the CPU, or the world.", false)
+	mm.new_game_menu.Hide()
+	mm.versus_menu.Show()
+	mm.versus_status.Show()
+}
+
+func (mm *MainMenu) hideVersus() {
+	mm.versus_menu.Hide()
+	mm.address_input.Hide()
+	mm.versus_status.Hide()
+	mm.versus_waiting = false
+}
+
+// SetVersusStatus tells the player how hosting or joining a versus game is going. While waiting, the only thing they
+// can do is cancel.
+func (mm *MainMenu) SetVersusStatus(status string, waiting bool) {
+	mm.versus_waiting = waiting
+	if waiting {
+		mm.versus_status.SetupBorder("", "[Esc] Cancel")
+	} else {
+		mm.versus_status.SetupBorder("", "")
+	}
+	mm.versus_status.ChangeText(status)
+}
+
 type ControlsView struct {
 	ui.Element
 }
@@ -169,11 +579,14 @@ func (cv *ControlsView) Render() {
 	cv.DrawText(vec.Coord{0, 5}, 1, "Rotate CCW", col.Pair{gfx.COL_DEFAULT, col.NONE}, gfx.DRAW_TEXT_LEFT)
 	cv.DrawGlyph(vec.Coord{cv.Size().W - 1, 5}, 1, gfx.GLYPH_Z)
 
-	cv.DrawText(vec.Coord{0, 6}, 1, "Hold/Swap Piece", col.Pair{gfx.COL_DEFAULT, col.NONE}, gfx.DRAW_TEXT_LEFT)
-	cv.DrawGlyph(vec.Coord{cv.Size().W - 1, 6}, 1, gfx.GLYPH_X)
+	cv.DrawText(vec.Coord{0, 6}, 1, "Rotate 180", col.Pair{gfx.COL_DEFAULT, col.NONE}, gfx.DRAW_TEXT_LEFT)
+	cv.DrawGlyph(vec.Coord{cv.Size().W - 1, 6}, 1, gfx.GLYPH_A)
+
+	cv.DrawText(vec.Coord{0, 7}, 1, "Hold/Swap Piece", col.Pair{gfx.COL_DEFAULT, col.NONE}, gfx.DRAW_TEXT_LEFT)
+	cv.DrawGlyph(vec.Coord{cv.Size().W - 1, 7}, 1, gfx.GLYPH_X)
 
-	cv.DrawText(vec.Coord{0, 7}, 1, "Pause", col.Pair{gfx.COL_DEFAULT, col.NONE}, gfx.DRAW_TEXT_LEFT)
-	cv.DrawGlyph(vec.Coord{cv.Size().W - 3, 7}, 1, gfx.GLYPH_E)
-	cv.DrawGlyph(vec.Coord{cv.Size().W - 2, 7}, 1, gfx.GLYPH_S)
-	cv.DrawGlyph(vec.Coord{cv.Size().W - 1, 7}, 1, gfx.GLYPH_C)
+	cv.DrawText(vec.Coord{0, 8}, 1, "Pause", col.Pair{gfx.COL_DEFAULT, col.NONE}, gfx.DRAW_TEXT_LEFT)
+	cv.DrawGlyph(vec.Coord{cv.Size().W - 3, 8}, 1, gfx.GLYPH_E)
+	cv.DrawGlyph(vec.Coord{cv.Size().W - 2, 8}, 1, gfx.GLYPH_S)
+	cv.DrawGlyph(vec.Coord{cv.Size().W - 1, 8}, 1, gfx.GLYPH_C)
 }
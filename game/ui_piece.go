@@ -1,6 +1,7 @@
 package main
 
 import (
+	"github.com/bennicholls/tytris/engine"
 	"github.com/bennicholls/tyumi/gfx"
 	"github.com/bennicholls/tyumi/gfx/col"
 	"github.com/bennicholls/tyumi/gfx/ui"
@@ -10,8 +11,13 @@ import (
 type PieceElement struct {
 	ui.Element
 
-	piece Piece
-	ghost bool
+	piece   engine.Piece
+	ghost   bool
+	preview bool     // previews are trimmed down to the piece's blocks, and it's up to their parent to position them
+	grid    WellGrid // pieces in the playfield are laid out like the well. the zero grid is fine everywhere else
+
+	lock_ticks int // how far through its lock delay the piece is. pieces get brighter as they get closer to locking
+	lock_delay int
 }
 
 func (pe *PieceElement) Init(size vec.Dims, pos vec.Coord, depth int) {
@@ -21,51 +27,88 @@ func (pe *PieceElement) Init(size vec.Dims, pos vec.Coord, depth int) {
 		Colours: col.Pair{col.WHITE, col.FUSCHIA},
 	})
 
-	pe.piece.pType = NO_PIECE
+	pe.piece.Type = engine.NO_PIECE
+}
+
+// SetGrid changes how the piece's blocks are laid out. The piece is hidden until it's next updated.
+func (pe *PieceElement) SetGrid(grid WellGrid) {
+	pe.grid = grid
+	pe.piece = engine.Piece{Type: engine.NO_PIECE}
+	pe.Hide()
 }
 
-func (pe *PieceElement) UpdatePiece(p Piece) {
-	if p.pType == NO_PIECE {
+func (pe *PieceElement) UpdatePiece(p engine.Piece) {
+	if p.Type == engine.NO_PIECE {
 		pe.Hide()
 		return
 	} else {
 		pe.Show()
 	}
 
-	if pe.piece.pType == NO_PIECE || pe.piece.Dims() != p.Dims() {
-		pe.Resize(p.Dims())
+	if pe.piece.Type == engine.NO_PIECE || pe.area(pe.piece).Dims != pe.area(p).Dims {
+		scale := pe.grid.Scale()
+		pe.Resize(vec.Dims{pe.area(p).W * scale, pe.area(p).H * scale})
+		pe.Clear()
 		pe.Updated = true
-	} else if pe.piece.pType != p.pType || pe.piece.rotation != p.rotation {
+	} else if pe.piece.Type != p.Type || pe.piece.Rotation != p.Rotation {
 		pe.Clear()
 		pe.Updated = true
 		pe.GetParent().ForceRedraw()
 	}
 
-	if pe.piece.pos != p.pos {
-		pe.MoveTo(p.pos)
+	if !pe.preview {
+		pe.MoveTo(pe.grid.Cell(p.Pos))
 	}
 
 	pe.piece = p
 }
 
+// area returns the part of the piece's bounding box that the element shows. Previews only show the blocks.
+func (pe *PieceElement) area(p engine.Piece) vec.Rect {
+	if pe.preview {
+		return p.Bounds()
+	}
+
+	return vec.Rect{vec.ZERO_COORD, p.Dims()}
+}
+
+// SetLockTimer updates the piece's progress through its lock delay.
+func (pe *PieceElement) SetLockTimer(ticks, delay int) {
+	if pe.lock_ticks == ticks && pe.lock_delay == delay {
+		return
+	}
+
+	pe.lock_ticks = ticks
+	pe.lock_delay = delay
+	pe.Updated = true
+}
+
 func (pe *PieceElement) Render() {
-	if pe.piece.pType == NO_PIECE {
+	if pe.piece.Type == engine.NO_PIECE {
 		return
 	}
 
 	shape := pe.piece.GetShape()
 	stride := pe.piece.Stride()
-	for i, piece_block := range shape {
-		offset := vec.IndexToCoord(i, stride)
-		if piece_block {
+	scale := pe.grid.Scale()
+	offset := pe.area(pe.piece).Coord
+	for cell := range vec.EachCoordInArea(pe.Canvas) {
+		i := vec.Coord{cell.X / scale, cell.Y / scale}.Add(offset).ToIndex(stride)
+		if shape[i] {
 			glyph := gfx.GLYPH_NONE
 			if pe.ghost {
-				drawBlock(&pe.Canvas, offset, glyph, col.DARKGREY, col.NONE)
+				drawBlock(&pe.Canvas, cell, glyph, col.DARKGREY, col.NONE)
 			} else {
-				if i < stride || !shape[i-stride] {
+				// only the top row of cells in a block gets the highlight
+				if cell.Y%scale == 0 && (i < stride || !shape[i-stride]) {
 					glyph = gfx.GLYPH_HALFBLOCK_UP
 				}
-				drawBlock(&pe.Canvas, offset, glyph, pe.piece.Colour(), pe.piece.Highlight())
+				colour, highlight := pe.piece.Colour(), pe.piece.Highlight()
+				if pe.lock_ticks > 0 && pe.lock_delay > 0 {
+					colour = col.Lerp(colour, col.WHITE, pe.lock_ticks, pe.lock_delay*2)
+					highlight = col.Lerp(highlight, col.WHITE, pe.lock_ticks, pe.lock_delay*2)
+				}
+				drawBlock(&pe.Canvas, cell, glyph, colour, highlight)
 			}
 		}
 	}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bennicholls/tytris/engine"
+	"github.com/bennicholls/tytris/versus"
+	"github.com/bennicholls/tyumi/gfx/ui"
+	"github.com/bennicholls/tyumi/log"
+)
+
+// versusPlayer describes the player to their opponent. Both players need the same rules to play each other, so the
+// settings that change how the game plays are part of it.
+func (s Settings) versusPlayer() versus.Player {
+	rules := fmt.Sprintf("%dx%d %s %s %s %s lock %d %s %d", s.WellSize.W, s.WellSize.H, s.Randomizer, s.PieceSet,
+		s.GravityCurve, s.RotationSystem, s.LockDelay, s.LockReset, s.MaxLockResets)
+	return versus.Player{Name: s.PlayerName, Rules: rules}
+}
+
+func (t *TyTris) hostVersus() {
+	seed := t.settings.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	menu := ui.GetLabelled[*MainMenu](t.Window(), "menu")
+	pending, err := versus.Host("", t.settings.versusPlayer(), seed)
+	if err != nil {
+		log.Error("Could not host versus game: ", err)
+		menu.SetVersusStatus("Could not host: "+err.Error(), false)
+		return
+	}
+
+	t.versus_pending = pending
+	menu.SetVersusStatus("Waiting for an opponent on port "+strconv.Itoa(versus.DEFAULT_PORT)+"...", true)
+}
+
+func (t *TyTris) joinVersus(address string) {
+	t.versus_pending = versus.Join(address, t.settings.versusPlayer())
+	ui.GetLabelled[*MainMenu](t.Window(), "menu").SetVersusStatus("Connecting to "+address+"...", true)
+}
+
+func (t *TyTris) cancelVersus() {
+	if t.versus_pending != nil {
+		t.versus_pending.Cancel()
+		t.versus_pending = nil
+	}
+}
+
+// updateVersus checks on the connection to the opponent, starting the game once there is one and then passing on
+// whatever the opponent sends.
+func (t *TyTris) updateVersus() {
+	if t.versus_pending != nil {
+		session, err := t.versus_pending.Poll()
+		if err != nil {
+			log.Error("Could not start versus game: ", err)
+			ui.GetLabelled[*MainMenu](t.Window(), "menu").SetVersusStatus("Could not connect: "+err.Error(), false)
+			t.versus_pending = nil
+		} else if session != nil {
+			t.versus_pending = nil
+			t.startVersus(session)
+		}
+		return
+	}
+
+	// once the result is in there's nothing more to hear from the opponent
+	if t.versus == nil || t.versus_won {
+		return
+	}
+
+	for {
+		msg, ok := t.versus.Poll()
+		if !ok {
+			break
+		}
+
+		switch msg.Type {
+		case versus.MSG_ATTACK:
+			for range msg.Lines {
+				t.solo.addAction(engine.ACTION_GARBAGE)
+			}
+		case versus.MSG_BOARD:
+			t.opponentView.SetBoard(msg.Board)
+		case versus.MSG_GAME_OVER:
+			t.versus_won = true
+			fireStateChangeEvent(GAME_OVER)
+			return
+		}
+	}
+
+	if err := t.versus.Err(); err != nil {
+		log.Info("Versus game ended early: ", err)
+		t.versus_won = true
+		t.versus_forfeit = true
+		fireStateChangeEvent(GAME_OVER)
+	}
+}
+
+// startVersus starts a game against the opponent on the other end of the session.
+func (t *TyTris) startVersus(session *versus.Session) {
+	log.Info("Starting versus game against ", session.Opponent, " with seed ", session.Seed)
+	t.versus = session
+	t.versus_won = false
+	t.versus_forfeit = false
+	t.opponentView.SetOpponent(session.Opponent)
+	fireNewGameEvent(Mode{Type: MODE_VERSUS})
+}
+
+// sendVersus sends a message to the opponent, if there is one. It's sent in the background, so a slow connection
+// doesn't hold up the game.
+func (t *TyTris) sendVersus(msg versus.Message) {
+	if t.versus == nil {
+		return
+	}
+
+	if err := t.versus.Send(msg); err != nil {
+		log.Warning("Could not send to opponent: ", err)
+	}
+}
+
+// sendBoard shows the opponent what the player's board looks like now. If the connection is slow, boards that haven't
+// been sent yet are replaced by this one.
+func (t *TyTris) sendBoard() {
+	if t.versus == nil {
+		return
+	}
+
+	t.sendVersus(versus.Message{Type: versus.MSG_BOARD, Board: t.solo.game.Board().Rows()})
+}
+
+// endVersus tells the opponent the player lost (unless they already won) and hangs up.
+func (t *TyTris) endVersus() {
+	if t.versus == nil {
+		return
+	}
+
+	if !t.versus_won {
+		t.sendVersus(versus.Message{Type: versus.MSG_GAME_OVER})
+	}
+
+	t.versus.Close()
+	t.versus = nil
+}
@@ -0,0 +1,242 @@
+// Package jsonl trades messages with something on the other end of a pipe or connection, as lines of json. Messages
+// are read and written in the background, so a slow or stuck peer never holds up the game. It's the plumbing shared by
+// external bots and versus games.
+package jsonl
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+)
+
+// messages waiting to be sent. if the peer falls this far behind reading them, it's considered stuck
+const send_queue_size = 64
+
+// most bytes a single message can take up
+const max_message_size = 1 << 20
+
+// Conn is a connection to a peer that speaks in messages of type M.
+type Conn[M any] struct {
+	// Latest reports whether a message is only worth sending if it's the latest of its kind, like a snapshot of
+	// something that keeps changing. Only one waits in the queue at a time, and newer ones replace it. Optional.
+	Latest func(M) bool
+
+	// Stop checks every message received. If it returns an error, the peer is done talking and reading stops with that
+	// error. Optional.
+	Stop func(M) error
+
+	name    string // what to call the peer in errors
+	scanner *bufio.Scanner
+	w       io.WriteCloser
+
+	send_mutex    sync.Mutex
+	outgoing      chan M
+	latest        M // the latest Latest message, sent when the queued one comes up
+	latest_queued bool
+	closed        bool // no more messages can be sent
+
+	incoming  chan M
+	done      bool          // set once everything the peer sent has been polled
+	closing   chan struct{} // closed by Close, after which nobody's listening to the peer
+	read_done chan struct{} // closed once the peer has stopped sending
+
+	err_mutex sync.Mutex
+	err       error
+}
+
+// New makes a connection that reads messages from r and writes them to w, which is closed once the connection is. name
+// is what to call the peer in errors. Nothing happens in the background until Start() is called, so until then
+// Receive() and Write() can be used for a handshake.
+func New[M any](name string, r io.Reader, w io.WriteCloser) *Conn[M] {
+	c := &Conn[M]{
+		name:      name,
+		scanner:   bufio.NewScanner(r),
+		w:         w,
+		outgoing:  make(chan M, send_queue_size),
+		incoming:  make(chan M, 64),
+		closing:   make(chan struct{}),
+		read_done: make(chan struct{}),
+	}
+	c.scanner.Buffer(nil, max_message_size)
+
+	return c
+}
+
+// Start reads and writes messages in the background until the connection is closed. ended is the reason given by
+// Err() if the peer stops sending without being stopped.
+func (c *Conn[M]) Start(ended error) {
+	go c.read(ended)
+	go c.write()
+}
+
+// Receive blocks until a message arrives. For handshakes, before Start() is called.
+func (c *Conn[M]) Receive() (msg M, err error) {
+	if !c.scanner.Scan() {
+		err = c.scanner.Err()
+		if err == nil {
+			err = errors.New("connection closed")
+		}
+		return
+	}
+
+	err = json.Unmarshal(c.scanner.Bytes(), &msg)
+	return
+}
+
+// Write sends a message, waiting until it's sent. For handshakes, before Start() is called.
+func (c *Conn[M]) Write(msg M) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.w.Write(append(data, '\n'))
+	return err
+}
+
+// read reads messages from the peer until it stops sending, or stops making sense.
+func (c *Conn[M]) read(ended error) {
+	defer close(c.read_done)
+	defer close(c.incoming)
+
+	for c.scanner.Scan() {
+		var msg M
+		if err := json.Unmarshal(c.scanner.Bytes(), &msg); err != nil {
+			c.SetErr(errors.New(c.name + " sent a bad message: " + err.Error()))
+			continue
+		}
+
+		if c.Stop != nil {
+			if err := c.Stop(msg); err != nil {
+				c.SetErr(err)
+				return
+			}
+		}
+
+		select {
+		case c.incoming <- msg:
+		case <-c.closing: // nobody's listening any more, but the peer might need everything read before it can leave
+		}
+	}
+
+	c.SetErr(ended)
+}
+
+// write sends queued messages until the connection is closed, then closes the writer.
+func (c *Conn[M]) write() {
+	defer c.w.Close()
+
+	var failed bool // once a write fails, there's no point trying any more
+	for msg := range c.outgoing {
+		if c.Latest != nil && c.Latest(msg) {
+			c.send_mutex.Lock()
+			msg = c.latest
+			c.latest_queued = false
+			c.send_mutex.Unlock()
+		}
+
+		if failed {
+			continue
+		}
+
+		if err := c.Write(msg); err != nil {
+			c.SetErr(err)
+			failed = true
+		}
+	}
+}
+
+// Poll returns the next message from the peer without waiting. ok is false if there are no messages.
+func (c *Conn[M]) Poll() (msg M, ok bool) {
+	if c.done {
+		return
+	}
+
+	select {
+	case msg, ok = <-c.incoming:
+		if !ok {
+			c.done = true
+		}
+	default:
+	}
+
+	return
+}
+
+// Send queues a message to be sent, without waiting for it to be sent. Latest messages replace any older one that
+// hasn't been sent yet, and are quietly dropped if the queue is full. It's safe to call from multiple goroutines.
+func (c *Conn[M]) Send(msg M) error {
+	c.send_mutex.Lock()
+	defer c.send_mutex.Unlock()
+
+	if c.closed {
+		return errors.New("connection closed")
+	}
+
+	latest := c.Latest != nil && c.Latest(msg)
+	if latest {
+		c.latest = msg
+		if c.latest_queued {
+			return nil // the older one hasn't been sent yet, so this one goes in its place
+		}
+	}
+
+	select {
+	case c.outgoing <- msg:
+		c.latest_queued = c.latest_queued || latest
+		return nil
+	default:
+		if latest {
+			return nil // it can wait for the next one
+		}
+
+		err := errors.New(c.name + " isn't keeping up with messages")
+		c.SetErr(err)
+		return err
+	}
+}
+
+// Err returns the reason the connection ended, once all of the peer's messages have been polled. Returns nil while
+// it's still going.
+func (c *Conn[M]) Err() error {
+	if !c.done {
+		return nil
+	}
+
+	c.err_mutex.Lock()
+	defer c.err_mutex.Unlock()
+
+	return c.err
+}
+
+// SetErr records why the connection ended, unless there's already a reason.
+func (c *Conn[M]) SetErr(err error) {
+	c.err_mutex.Lock()
+	defer c.err_mutex.Unlock()
+
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// Close stops sending once everything already queued has been sent, and stops listening to the peer. It doesn't wait
+// around for any of that to happen, see ReadDone().
+func (c *Conn[M]) Close() {
+	c.send_mutex.Lock()
+	defer c.send_mutex.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	c.closed = true
+	close(c.outgoing)
+	close(c.closing)
+}
+
+// ReadDone is closed once the peer has stopped sending, and everything it sent has been read.
+func (c *Conn[M]) ReadDone() <-chan struct{} {
+	return c.read_done
+}
@@ -0,0 +1,81 @@
+package tbp
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bennicholls/tytris/jsonl"
+)
+
+// how long a bot gets to exit after being asked to quit, before it's killed
+const quit_timeout = time.Second
+
+// Bot is an external bot, running as a subprocess. Messages are read and written in the background, so a bot that
+// stops reading doesn't hold up the game.
+type Bot struct {
+	cmd        *exec.Cmd
+	conn       *jsonl.Conn[Message]
+	close_once sync.Once
+}
+
+// Launch starts a bot. The command is split on spaces into the program and its arguments. The bot introduces itself
+// with an info message once it's running.
+func Launch(command string) (*Bot, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, errors.New("no command to launch the bot with")
+	}
+
+	b := &Bot{cmd: exec.Command(args[0], args[1:]...)}
+	stdin, err := b.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := b.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	b.conn = jsonl.New[Message]("bot", stdout, stdin)
+	b.conn.Start(errors.New("bot exited"))
+
+	return b, nil
+}
+
+// Poll returns the next message from the bot without waiting. ok is false if there are no messages.
+func (b *Bot) Poll() (msg Message, ok bool) {
+	return b.conn.Poll()
+}
+
+// Send queues a message to be sent to the bot, without waiting for it to be sent.
+func (b *Bot) Send(msg Message) error {
+	return b.conn.Send(msg)
+}
+
+// Err returns the reason the bot stopped talking, once all of its messages have been polled. Returns nil while it's
+// still running.
+func (b *Bot) Err() error {
+	return b.conn.Err()
+}
+
+// Close asks the bot to quit, and kills it if it hasn't after a second. Doesn't wait around to find out.
+func (b *Bot) Close() {
+	b.close_once.Do(func() {
+		b.conn.Send(Message{Type: MSG_QUIT})
+		b.conn.Close()
+
+		go func() {
+			kill := time.AfterFunc(quit_timeout, func() { b.cmd.Process.Kill() })
+			<-b.conn.ReadDone() // Wait closes stdout, so everything has to be read first
+			b.cmd.Wait()
+			kill.Stop()
+		}()
+	})
+}
@@ -0,0 +1,160 @@
+package tbp
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bennicholls/tytris/engine"
+	"github.com/bennicholls/tyumi/vec"
+)
+
+// how long the tests wait for the stub to answer before failing
+const test_timeout = 5 * time.Second
+
+// launchStub builds the stub bot and launches it.
+func launchStub(t *testing.T) *Bot {
+	t.Helper()
+
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("can't build the stub bot without the go command")
+	}
+
+	stub := filepath.Join(t.TempDir(), "stub")
+	if out, err := exec.Command("go", "build", "-o", stub, "./stub").CombinedOutput(); err != nil {
+		t.Fatalf("could not build the stub bot: %v\n%s", err, out)
+	}
+
+	bot, err := Launch(stub)
+	if err != nil {
+		t.Fatal("could not launch the stub bot: ", err)
+	}
+
+	return bot
+}
+
+// receive polls the bot until a message arrives. ok is false if the bot stopped talking first.
+func receive(t *testing.T, b *Bot) (msg Message, ok bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(test_timeout); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if msg, ok = b.Poll(); ok || b.Err() != nil {
+			return
+		}
+	}
+
+	t.Fatal("timed out waiting for the bot")
+	return
+}
+
+// expect receives a message from the bot and fails the test if it isn't the expected type.
+func expect(t *testing.T, b *Bot, msg_type string) Message {
+	t.Helper()
+
+	msg, ok := receive(t, b)
+	if !ok {
+		t.Fatal("bot stopped talking: ", b.Err())
+	}
+	if msg.Type != msg_type {
+		t.Fatalf("got %+v, want a %s message", msg, msg_type)
+	}
+
+	return msg
+}
+
+// suggestion asks the bot where the current piece goes, and checks the piece can lock there.
+func suggestion(t *testing.T, b *Bot, game *engine.Game) Move {
+	t.Helper()
+
+	if err := b.Send(Message{Type: MSG_SUGGEST}); err != nil {
+		t.Fatal("could not send: ", err)
+	}
+
+	msg := expect(t, b, MSG_SUGGESTION)
+	if len(msg.Moves) == 0 {
+		t.Fatal("bot suggested no moves")
+	}
+
+	move := msg.Moves[0]
+	if want := game.CurrentPiece().Type.Letter(); move.Location.Type != want {
+		t.Fatalf("bot suggested a spot for %s, the current piece is %s", move.Location.Type, want)
+	}
+
+	piece, err := ToPiece(move.Location, game.Board().Size().H)
+	if err != nil {
+		t.Fatal("bot suggested a bad location: ", err)
+	}
+
+	below := piece
+	below.Pos = below.Pos.Step(vec.DIR_DOWN)
+	if !game.Board().IsValidPosition(piece) || game.Board().IsValidPosition(below) {
+		t.Fatalf("bot suggested %+v, where the piece can't lock", move.Location)
+	}
+
+	return move
+}
+
+func TestStub(t *testing.T) {
+	bot := launchStub(t)
+	defer bot.Close()
+
+	if info := expect(t, bot, MSG_INFO); info.Name != "Stub" {
+		t.Errorf("bot introduced itself as %q", info.Name)
+	}
+
+	bot.Send(Message{Type: MSG_RULES})
+	expect(t, bot, MSG_READY)
+
+	config := engine.DefaultConfig()
+	config.Seed = 1
+	var game engine.Game
+	game.Init(config)
+	game.Step(nil) // spawns the first piece
+
+	if err := Supports(game.Config()); err != nil {
+		t.Fatal("bots can't play the default rules: ", err)
+	}
+
+	bot.Send(Start(&game))
+	move := suggestion(t, bot, &game)
+
+	// play the suggestion, and tell the bot about the piece that joins the end of the queue
+	piece, _ := ToPiece(move.Location, game.Board().Size().H)
+	for game.CurrentPiece().Rotation != piece.Rotation {
+		game.Step([]engine.Action{engine.ACTION_ROTATE_CW})
+	}
+	for game.CurrentPiece().Pos.X != piece.Pos.X {
+		if game.CurrentPiece().Pos.X < piece.Pos.X {
+			game.Step([]engine.Action{engine.ACTION_MOVE_RIGHT, engine.ACTION_RELEASE_RIGHT})
+		} else {
+			game.Step([]engine.Action{engine.ACTION_MOVE_LEFT, engine.ACTION_RELEASE_LEFT})
+		}
+	}
+	game.Step([]engine.Action{engine.ACTION_HARD_DROP})
+	game.Step(nil) // spawns the next piece
+
+	for block := range piece.EachBlock() {
+		if game.Board().Block(block) != piece.Type {
+			t.Fatalf("suggestion wasn't played where the bot asked: no block at %v", block)
+		}
+	}
+
+	bot.Send(Message{Type: MSG_PLAY, Move: &move})
+	upcoming := game.UpcomingPieces(engine.UPCOMING_PIECES)
+	bot.Send(Message{Type: MSG_NEW_PIECE, Piece: upcoming[len(upcoming)-1].Type.Letter()})
+	suggestion(t, bot, &game)
+
+	// asked to quit, the bot exits
+	bot.Close()
+	for {
+		if _, ok := receive(t, bot); !ok {
+			break
+		}
+	}
+
+	if err := bot.Err(); err == nil || !strings.Contains(err.Error(), "exited") {
+		t.Errorf("wrong reason for the bot stopping: %v", err)
+	}
+}
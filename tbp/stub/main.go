@@ -0,0 +1,194 @@
+// Command stub is a very simple bot that speaks the Tetris Bot Protocol, for testing the game's support for external
+// bots without needing a real one. It drops the current piece straight down wherever it leaves the lowest, flattest
+// stack, and never holds or spins.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+
+	"github.com/bennicholls/tytris/tbp"
+	"github.com/bennicholls/tyumi/vec"
+)
+
+// the orientations a piece can be pointed
+var orientations = []string{"north", "east", "south", "west"}
+
+// stub is the bot's idea of the game.
+type stub struct {
+	board [][]bool // bottom row first
+	queue []string
+	hold  string
+}
+
+func main() {
+	out := json.NewEncoder(os.Stdout)
+	out.Encode(tbp.Message{Type: tbp.MSG_INFO, Name: "Stub", Version: "1.0", Author: "tytris", Features: []string{}})
+
+	var s stub
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var msg tbp.Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+
+		switch msg.Type {
+		case tbp.MSG_RULES:
+			out.Encode(tbp.Message{Type: tbp.MSG_READY})
+		case tbp.MSG_START:
+			s.start(msg)
+		case tbp.MSG_NEW_PIECE:
+			s.queue = append(s.queue, msg.Piece)
+		case tbp.MSG_PLAY:
+			if msg.Move != nil {
+				s.play(msg.Move.Location)
+			}
+		case tbp.MSG_SUGGEST:
+			out.Encode(tbp.Message{Type: tbp.MSG_SUGGESTION, Moves: s.suggest()})
+		case tbp.MSG_QUIT:
+			return
+		}
+	}
+}
+
+func (s *stub) start(msg tbp.Message) {
+	s.board = make([][]bool, tbp.BOARD_HEIGHT)
+	for y := range s.board {
+		s.board[y] = make([]bool, tbp.BOARD_WIDTH)
+		if y < len(msg.Board) {
+			for x, cell := range msg.Board[y] {
+				s.board[y][x] = cell != nil
+			}
+		}
+	}
+
+	s.queue = msg.Queue
+	s.hold = ""
+	if msg.Hold != nil {
+		s.hold = *msg.Hold
+	}
+}
+
+// play locks the piece and moves on to the next one. if the piece isn't the one at the front of the queue, it was
+// swapped in from hold.
+func (s *stub) play(loc tbp.Location) {
+	cells, err := loc.Blocks()
+	if err != nil || len(s.queue) == 0 {
+		return
+	}
+
+	s.board = place(s.board, cells)
+
+	switch {
+	case loc.Type == s.queue[0]:
+		s.queue = s.queue[1:]
+	case s.hold == "": // the next piece was swapped in, so both come out of the queue
+		s.hold = s.queue[0]
+		s.queue = s.queue[min(2, len(s.queue)):]
+	default:
+		s.hold = s.queue[0]
+		s.queue = s.queue[1:]
+	}
+}
+
+// suggest tries dropping the current piece straight down in every column and orientation, best spot first.
+func (s *stub) suggest() (moves []tbp.Move) {
+	if len(s.queue) == 0 {
+		return
+	}
+
+	best := -1
+	for _, orientation := range orientations {
+		for x := range tbp.BOARD_WIDTH {
+			loc := tbp.Location{Type: s.queue[0], Orientation: orientation, X: x, Y: tbp.BOARD_HEIGHT - 3}
+			cells, err := loc.Blocks()
+			if err != nil || !fits(s.board, cells) {
+				continue
+			}
+
+			for fits(s.board, shift(cells, -1)) {
+				cells = shift(cells, -1)
+				loc.Y -= 1
+			}
+
+			score := evaluate(place(s.board, cells))
+			move := tbp.Move{Location: loc, Spin: "none"}
+			if best == -1 || score < best {
+				best = score
+				moves = append([]tbp.Move{move}, moves...)
+			} else {
+				moves = append(moves, move)
+			}
+		}
+	}
+
+	return
+}
+
+// evaluate scores a board. lower is better: every hole costs as much as a row of height.
+func evaluate(board [][]bool) (score int) {
+	for x := range tbp.BOARD_WIDTH {
+		covered := false
+		for y := len(board) - 1; y >= 0; y-- {
+			switch {
+			case board[y][x] && !covered:
+				covered = true
+				score = max(score, y+1)
+			case !board[y][x] && covered:
+				score += 1
+			}
+		}
+	}
+
+	return
+}
+
+func fits(board [][]bool, cells []vec.Coord) bool {
+	for _, cell := range cells {
+		if cell.X < 0 || cell.X >= tbp.BOARD_WIDTH || cell.Y < 0 || cell.Y >= len(board) || board[cell.Y][cell.X] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func shift(cells []vec.Coord, dy int) (shifted []vec.Coord) {
+	for _, cell := range cells {
+		shifted = append(shifted, vec.Coord{cell.X, cell.Y + dy})
+	}
+
+	return
+}
+
+// place returns a copy of the board with the cells filled in and any full lines cleared.
+func place(board [][]bool, cells []vec.Coord) (placed [][]bool) {
+	for _, row := range board {
+		placed = append(placed, append([]bool(nil), row...))
+	}
+
+	for _, cell := range cells {
+		if cell.Y >= 0 && cell.Y < len(placed) {
+			placed[cell.Y][cell.X] = true
+		}
+	}
+
+	cleared := placed[:0]
+	for _, row := range placed {
+		full := true
+		for _, filled := range row {
+			full = full && filled
+		}
+		if !full {
+			cleared = append(cleared, row)
+		}
+	}
+	for len(cleared) < len(board) {
+		cleared = append(cleared, make([]bool, tbp.BOARD_WIDTH))
+	}
+
+	return cleared
+}
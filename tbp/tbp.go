@@ -0,0 +1,273 @@
+// Package tbp speaks the Tetris Bot Protocol (https://github.com/tetris-bot-protocol/tbp-spec), which lets external bot
+// engines like Cold Clear play tytris. Bots run as subprocesses and trade messages with the game as lines of json over
+// their stdin and stdout. This package only handles the messages and the process, and converts between the protocol's
+// pieces and boards and the engine's. Deciding what to do with a bot's suggestions is up to the caller.
+package tbp
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/bennicholls/tytris/engine"
+	"github.com/bennicholls/tyumi/vec"
+)
+
+// the protocol only knows about the standard well, 10 wide with 40 rows
+const (
+	BOARD_WIDTH  int = 10
+	BOARD_HEIGHT int = 40
+)
+
+// Message types. The first few are sent by bots, the rest by the game.
+const (
+	MSG_INFO       = "info"       // sent by the bot once it starts, with its name, version and author
+	MSG_READY      = "ready"      // the bot is happy with the rules and ready to play
+	MSG_ERROR      = "error"      // the bot can't play, with the reason
+	MSG_SUGGESTION = "suggestion" // the bot's moves for the current piece, best first
+	MSG_RULES      = "rules"      // the rules of the game. the bot answers with ready or error
+	MSG_START      = "start"      // the state of the game, to start thinking about
+	MSG_STOP       = "stop"       // stop thinking about the game, until the next start
+	MSG_SUGGEST    = "suggest"    // asks the bot for a suggestion
+	MSG_PLAY       = "play"       // the move that was played, so the bot can move on to the next piece
+	MSG_NEW_PIECE  = "new_piece"  // a piece was added to the end of the queue
+	MSG_QUIT       = "quit"       // the bot should exit
+)
+
+// Message is every message in the protocol, with only the fields that make sense for its type filled in. Fields the
+// protocol always includes for a type aren't omitted when empty, so they're pointers or set to nil as appropriate.
+type Message struct {
+	Type string `json:"type"`
+
+	// info
+	Name     string   `json:"name,omitempty"`
+	Version  string   `json:"version,omitempty"`
+	Author   string   `json:"author,omitempty"`
+	Features []string `json:"features,omitempty"`
+
+	// error
+	Reason string `json:"reason,omitempty"`
+
+	// suggestion
+	Moves []Move `json:"moves,omitempty"`
+
+	// start
+	Hold       *string     `json:"hold,omitempty"` // the held piece's letter. only omitted when nothing is held
+	Queue      []string    `json:"queue,omitempty"`
+	Combo      *int        `json:"combo,omitempty"`
+	BackToBack *bool       `json:"back_to_back,omitempty"`
+	Board      [][]*string `json:"board,omitempty"` // bottom row first. empty cells are nil
+
+	// play
+	Move *Move `json:"move,omitempty"`
+
+	// new_piece
+	Piece string `json:"piece,omitempty"`
+}
+
+// Move is where a piece goes.
+type Move struct {
+	Location Location `json:"location"`
+	Spin     string   `json:"spin"` // "none", "mini" or "full"
+}
+
+// Location is a piece in the well. X and Y are the piece's center, counting up from the bottom left of the well.
+type Location struct {
+	Type        string `json:"type"`
+	Orientation string `json:"orientation"`
+	X           int    `json:"x"`
+	Y           int    `json:"y"`
+}
+
+// orientations, in the same order as the engine's rotation states
+var orientations = []string{"north", "east", "south", "west"}
+
+// blocks of each piece pointing north, relative to its center with y pointing up. the other orientations are these
+// rotated clockwise around the center.
+var north_blocks = map[engine.PieceType][]vec.Coord{
+	engine.I: {{-1, 0}, {0, 0}, {1, 0}, {2, 0}},
+	engine.O: {{0, 0}, {1, 0}, {0, 1}, {1, 1}},
+	engine.T: {{-1, 0}, {0, 0}, {1, 0}, {0, 1}},
+	engine.L: {{-1, 0}, {0, 0}, {1, 0}, {1, 1}},
+	engine.J: {{-1, 0}, {0, 0}, {1, 0}, {-1, 1}},
+	engine.S: {{-1, 0}, {0, 0}, {0, 1}, {1, 1}},
+	engine.Z: {{-1, 1}, {0, 1}, {0, 0}, {1, 0}},
+}
+
+// Supports reports whether bots can play a game with these rules. The protocol only knows about the standard pieces
+// in a standard width well.
+func Supports(config engine.Config) error {
+	if config.WellSize.W != BOARD_WIDTH {
+		return fmt.Errorf("bots can only play in a well %d wide", BOARD_WIDTH)
+	}
+
+	for _, piece := range config.Pieces.Pieces {
+		if _, ok := north_blocks[piece]; !ok {
+			return errors.New("bots can only play with the standard pieces")
+		}
+	}
+
+	return nil
+}
+
+// Start describes the game for a start message. The current piece goes at the front of the queue.
+func Start(game *engine.Game) Message {
+	msg := Message{Type: MSG_START, Combo: new(int), BackToBack: new(bool)}
+	if held := game.HeldPiece(); held.Type != engine.NO_PIECE {
+		letter := held.Type.Letter()
+		msg.Hold = &letter
+	}
+
+	msg.Queue = []string{}
+	if current := game.CurrentPiece(); current.Type != engine.NO_PIECE {
+		msg.Queue = append(msg.Queue, current.Type.Letter())
+	}
+	for _, piece := range game.UpcomingPieces(engine.UPCOMING_PIECES) {
+		if piece.Type != engine.NO_PIECE {
+			msg.Queue = append(msg.Queue, piece.Type.Letter())
+		}
+	}
+
+	*msg.Combo = game.Combo()
+	*msg.BackToBack = game.BackToBack()
+	msg.Board = Board(game.Board())
+
+	return msg
+}
+
+// Board converts the engine's board to the protocol's, bottom row first and padded out to the protocol's height.
+func Board(board *engine.Board) (rows [][]*string) {
+	size := board.Size()
+	garbage := "G"
+	rows = make([][]*string, max(BOARD_HEIGHT, size.H))
+	for i := range rows {
+		rows[i] = make([]*string, size.W)
+		y := size.H - 1 - i
+		if y < 0 {
+			continue
+		}
+
+		for x := range size.W {
+			switch block := board.Block(vec.Coord{x, y}); {
+			case block == engine.NO_PIECE:
+			case block.Letter() == "":
+				rows[i][x] = &garbage
+			default:
+				letter := block.Letter()
+				rows[i][x] = &letter
+			}
+		}
+	}
+
+	return
+}
+
+// Blocks returns the cells covered by the piece at the location, counting up from the bottom left of the well.
+func (loc Location) Blocks() (cells []vec.Coord, err error) {
+	piece, err := pieceType(loc.Type)
+	if err != nil {
+		return
+	}
+
+	rotation := slices.Index(orientations, loc.Orientation)
+	if rotation == -1 {
+		return nil, errors.New("unknown orientation: " + loc.Orientation)
+	}
+
+	for _, block := range blocks(piece, rotation) {
+		cells = append(cells, vec.Coord{loc.X + block.X, loc.Y + block.Y})
+	}
+
+	return
+}
+
+// ToPiece finds the engine's piece at a location, in a well of the provided height.
+func ToPiece(loc Location, well_height int) (piece engine.Piece, err error) {
+	cells, err := loc.Blocks()
+	if err != nil {
+		return
+	}
+
+	// blocks in well coordinates, with y pointing down
+	var want []vec.Coord
+	for _, cell := range cells {
+		want = append(want, vec.Coord{cell.X, well_height - 1 - cell.Y})
+	}
+
+	// pieces that don't rotate in the engine can still be pointed any way in the protocol, so try them unrotated
+	piece.Type, _ = pieceType(loc.Type)
+	piece.Rotate(slices.Index(orientations, loc.Orientation))
+	piece.Pos = topLeft(want).Subtract(topLeft(slices.Collect(piece.EachBlock())))
+	if !sameBlocks(slices.Collect(piece.EachBlock()), want) {
+		return piece, fmt.Errorf("can't place %s piece pointing %s", loc.Type, loc.Orientation)
+	}
+
+	return
+}
+
+// FromPiece finds the location of one of the engine's pieces, in a well of the provided height.
+func FromPiece(piece engine.Piece, well_height int) (loc Location, err error) {
+	if _, ok := north_blocks[piece.Type]; !ok {
+		return loc, errors.New("custom pieces aren't supported")
+	}
+
+	loc.Type = piece.Type.Letter()
+	loc.Orientation = orientations[piece.Rotation]
+
+	// flip the piece's blocks so y points up, then line them up with the protocol's blocks to find the center
+	var have []vec.Coord
+	for block := range piece.EachBlock() {
+		have = append(have, vec.Coord{block.X, well_height - 1 - block.Y})
+	}
+	center := topLeft(have).Subtract(topLeft(blocks(piece.Type, piece.Rotation)))
+	loc.X, loc.Y = center.X, center.Y
+
+	return
+}
+
+// blocks returns the blocks of a piece in one of the orientations, relative to its center with y pointing up.
+func blocks(piece engine.PieceType, rotation int) (rotated []vec.Coord) {
+	for _, block := range north_blocks[piece] {
+		for range rotation {
+			block = vec.Coord{block.Y, -block.X}
+		}
+		rotated = append(rotated, block)
+	}
+
+	return
+}
+
+// topLeft returns the smallest x and y of the blocks. they don't have to come from the same block, it just has to be
+// a corner that two sets of the same blocks agree on.
+func topLeft(blocks []vec.Coord) (corner vec.Coord) {
+	corner = blocks[0]
+	for _, block := range blocks {
+		corner = vec.Coord{min(corner.X, block.X), min(corner.Y, block.Y)}
+	}
+
+	return
+}
+
+func sameBlocks(a, b []vec.Coord) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for _, block := range a {
+		if !slices.Contains(b, block) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// pieceType finds the engine's piece for a letter.
+func pieceType(letter string) (engine.PieceType, error) {
+	pieces, err := engine.ParseSequence(letter)
+	if err != nil || len(pieces) != 1 {
+		return engine.NO_PIECE, errors.New("unknown piece: " + letter)
+	}
+
+	return pieces[0], nil
+}
@@ -0,0 +1,330 @@
+// Package versus connects two games of tytris over TCP, so they can be played against each other. It only handles
+// the connection: the games themselves run on each player's machine and just tell each other about attacks, their
+// boards and when they lose.
+package versus
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bennicholls/tytris/jsonl"
+)
+
+// PROTOCOL_VERSION is the version of the messages sent between games. Bump it whenever the messages change, or the
+// rules change in a way that would make games on different versions play differently.
+const PROTOCOL_VERSION = 1
+
+// DEFAULT_PORT is used for hosting, and for joining addresses that don't give a port.
+const DEFAULT_PORT = 7357
+
+// how long the games have to say hello to each other before giving up
+const handshake_timeout = 10 * time.Second
+
+// how long a message can take to send before the connection is considered dead
+const send_timeout = 5 * time.Second
+
+type MessageType int
+
+const (
+	MSG_HELLO     MessageType = iota // first message from each side. has the protocol version, player name and rules
+	MSG_START                        // sent by the host once both sides agree. has the seed both games use
+	MSG_ATTACK                       // lines of garbage sent to the opponent
+	MSG_BOARD                        // the sender's board, so the opponent can show it
+	MSG_GAME_OVER                    // the sender lost
+	MSG_BYE                          // the sender is leaving, with a reason
+)
+
+// Message is sent between games as a line of json. Only the fields that make sense for the message's type are set.
+type Message struct {
+	Type    MessageType
+	Version int      `json:",omitempty"`
+	Name    string   `json:",omitempty"`
+	Rules   string   `json:",omitempty"` // description of the game settings. both sides must have the same rules to play
+	Seed    int64    `json:",omitempty"`
+	Lines   int      `json:",omitempty"`
+	Board   []string `json:",omitempty"` // rows of the board, top row first, as written by engine.Board.Rows()
+	Reason  string   `json:",omitempty"`
+}
+
+// Player describes the local player to the opponent.
+type Player struct {
+	Name  string
+	Rules string
+}
+
+// Pending is a connection that is still being set up. Poll it until it produces a session or fails.
+type Pending struct {
+	result   chan pendingResult
+	listener net.Listener
+
+	mutex     sync.Mutex
+	conn      net.Conn
+	cancelled bool
+}
+
+type pendingResult struct {
+	session *Session
+	err     error
+}
+
+// Host starts listening for an opponent on addr (like ":7357"). An empty address listens on the default port. Once
+// someone joins with the same rules the game starts with the provided seed.
+func Host(addr string, me Player, seed int64) (*Pending, error) {
+	if addr == "" {
+		addr = ":" + strconv.Itoa(DEFAULT_PORT)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pending{result: make(chan pendingResult, 1), listener: listener}
+	go func() {
+		conn, err := listener.Accept()
+		listener.Close()
+		if err != nil {
+			p.finish(nil, err)
+			return
+		}
+
+		p.finish(hostHandshake(conn, me, seed))
+	}()
+
+	return p, nil
+}
+
+// Join connects to a game hosted at addr. If the address has no port the default one is used.
+func Join(addr string, me Player) *Pending {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, strconv.Itoa(DEFAULT_PORT))
+	}
+
+	p := &Pending{result: make(chan pendingResult, 1)}
+	go func() {
+		conn, err := net.DialTimeout("tcp", addr, handshake_timeout)
+		if err != nil {
+			p.finish(nil, err)
+			return
+		}
+
+		p.mutex.Lock()
+		p.conn = conn
+		cancelled := p.cancelled
+		p.mutex.Unlock()
+		if cancelled {
+			conn.Close()
+			p.finish(nil, errors.New("cancelled"))
+			return
+		}
+
+		p.finish(joinHandshake(conn, me))
+	}()
+
+	return p
+}
+
+// Addr returns the address a hosted game is listening on, or nil for a joining one.
+func (p *Pending) Addr() net.Addr {
+	if p.listener == nil {
+		return nil
+	}
+
+	return p.listener.Addr()
+}
+
+// Poll checks on the connection without waiting. It returns the session once both sides are ready to start, or an
+// error if the connection failed. Both are nil while it's still in progress.
+func (p *Pending) Poll() (*Session, error) {
+	select {
+	case result := <-p.result:
+		return result.session, result.err
+	default:
+		return nil, nil
+	}
+}
+
+// Cancel stops hosting or joining.
+func (p *Pending) Cancel() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.cancelled = true
+	if p.listener != nil {
+		p.listener.Close()
+	}
+	if p.conn != nil {
+		p.conn.Close()
+	}
+}
+
+func (p *Pending) finish(session *Session, err error) {
+	p.mutex.Lock()
+	cancelled := p.cancelled
+	p.mutex.Unlock()
+
+	if cancelled && session != nil {
+		session.Close()
+		session, err = nil, errors.New("cancelled")
+	}
+
+	p.result <- pendingResult{session, err}
+}
+
+// hostHandshake waits for the joining player to say hello, checks they're playing the same game and starts it.
+func hostHandshake(conn net.Conn, me Player, seed int64) (*Session, error) {
+	s := newSession(conn)
+	conn.SetDeadline(time.Now().Add(handshake_timeout))
+
+	hello, err := s.conn.Receive()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if hello.Type != MSG_HELLO {
+		conn.Close()
+		return nil, fmt.Errorf("expected hello, got message type %d", hello.Type)
+	}
+
+	if reason := checkHello(hello, me); reason != "" {
+		s.conn.Write(Message{Type: MSG_BYE, Reason: reason})
+		conn.Close()
+		return nil, errors.New(reason)
+	}
+
+	err = s.conn.Write(Message{Type: MSG_HELLO, Version: PROTOCOL_VERSION, Name: me.Name, Rules: me.Rules})
+	if err == nil {
+		err = s.conn.Write(Message{Type: MSG_START, Seed: seed})
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Time{})
+	s.Opponent = hello.Name
+	s.Seed = seed
+	s.start()
+
+	return s, nil
+}
+
+// joinHandshake says hello to the host and waits for the game to start.
+func joinHandshake(conn net.Conn, me Player) (*Session, error) {
+	s := newSession(conn)
+	conn.SetDeadline(time.Now().Add(handshake_timeout))
+
+	err := s.conn.Write(Message{Type: MSG_HELLO, Version: PROTOCOL_VERSION, Name: me.Name, Rules: me.Rules})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	for {
+		msg, err := s.conn.Receive()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		switch msg.Type {
+		case MSG_HELLO:
+			if reason := checkHello(msg, me); reason != "" {
+				conn.Close()
+				return nil, errors.New(reason)
+			}
+			s.Opponent = msg.Name
+		case MSG_START:
+			conn.SetDeadline(time.Time{})
+			s.Seed = msg.Seed
+			s.start()
+			return s, nil
+		case MSG_BYE:
+			conn.Close()
+			return nil, errors.New(msg.Reason)
+		default:
+			conn.Close()
+			return nil, fmt.Errorf("expected start, got message type %d", msg.Type)
+		}
+	}
+}
+
+// checkHello returns the reason the two players can't play each other, or "" if they can.
+func checkHello(hello Message, me Player) string {
+	if hello.Version != PROTOCOL_VERSION {
+		return fmt.Sprintf("version mismatch (ours %d, theirs %d)", PROTOCOL_VERSION, hello.Version)
+	}
+
+	if hello.Rules != me.Rules {
+		return fmt.Sprintf("rules don't match (ours %s, theirs %s)", me.Rules, hello.Rules)
+	}
+
+	return ""
+}
+
+// Session is a connection to an opponent, with a game ready to start or underway.
+type Session struct {
+	Opponent string // the opponent's name
+	Seed     int64  // the seed both games are played with
+
+	conn *jsonl.Conn[Message]
+}
+
+func newSession(conn net.Conn) *Session {
+	s := &Session{conn: jsonl.New[Message]("opponent", conn, timedConn{conn})}
+
+	// boards are only worth sending if they're the latest, so a slow connection only ever has one waiting
+	s.conn.Latest = func(msg Message) bool { return msg.Type == MSG_BOARD }
+	s.conn.Stop = func(msg Message) error {
+		if msg.Type == MSG_BYE {
+			return errors.New("opponent left: " + msg.Reason)
+		}
+		return nil
+	}
+
+	return s
+}
+
+// timedConn gives up on writes that take too long, so a dead connection can't hold up sending forever.
+type timedConn struct {
+	net.Conn
+}
+
+func (tc timedConn) Write(data []byte) (int, error) {
+	tc.SetWriteDeadline(time.Now().Add(send_timeout))
+	return tc.Conn.Write(data)
+}
+
+// start reads messages from the opponent and sends messages to them in the background.
+func (s *Session) start() {
+	s.conn.Start(errors.New("opponent disconnected"))
+}
+
+// Poll returns the next message from the opponent without waiting. ok is false if there are no messages.
+func (s *Session) Poll() (msg Message, ok bool) {
+	return s.conn.Poll()
+}
+
+// Send queues a message to be sent to the opponent, without waiting for it to be sent. A board replaces any older
+// board that hasn't been sent yet. It's safe to call from multiple goroutines.
+func (s *Session) Send(msg Message) error {
+	return s.conn.Send(msg)
+}
+
+// Err returns the reason the connection ended, once all of the opponent's messages have been polled. Returns nil while
+// the connection is still up.
+func (s *Session) Err() error {
+	return s.conn.Err()
+}
+
+// Close says goodbye to the opponent and closes the connection, once everything queued before it has been sent. It
+// doesn't wait around for that to happen.
+func (s *Session) Close() {
+	s.conn.Send(Message{Type: MSG_BYE, Reason: "closed"})
+	s.conn.Close()
+}
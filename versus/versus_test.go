@@ -0,0 +1,284 @@
+package versus
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bennicholls/tytris/engine"
+)
+
+// how long the tests wait for something to happen over loopback before failing
+const test_timeout = 5 * time.Second
+
+var test_rules = "10x25 7bag"
+
+// connect hosts a game on loopback and joins it, returning the pending connections for both sides.
+func connect(t *testing.T, host, guest Player) (hosting, joining *Pending) {
+	t.Helper()
+
+	hosting, err := Host("127.0.0.1:0", host, 1234)
+	if err != nil {
+		t.Fatal("could not host: ", err)
+	}
+
+	return hosting, Join(hosting.Addr().String(), guest)
+}
+
+// wait polls a pending connection until it finishes.
+func wait(t *testing.T, p *Pending) (*Session, error) {
+	t.Helper()
+
+	for deadline := time.Now().Add(test_timeout); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if session, err := p.Poll(); session != nil || err != nil {
+			return session, err
+		}
+	}
+
+	t.Fatal("timed out waiting for the connection")
+	return nil, nil
+}
+
+// receive polls a session until a message arrives. ok is false if the connection ended first.
+func receive(t *testing.T, s *Session) (msg Message, ok bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(test_timeout); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if msg, ok = s.Poll(); ok || s.Err() != nil {
+			return
+		}
+	}
+
+	t.Fatal("timed out waiting for a message")
+	return
+}
+
+// start connects two players with the same rules, and fails the test if they can't play.
+func start(t *testing.T) (host, guest *Session) {
+	t.Helper()
+
+	hosting, joining := connect(t, Player{Name: "Host", Rules: test_rules}, Player{Name: "Guest", Rules: test_rules})
+
+	host, err := wait(t, hosting)
+	if err != nil {
+		t.Fatal("host handshake failed: ", err)
+	}
+
+	guest, err = wait(t, joining)
+	if err != nil {
+		t.Fatal("guest handshake failed: ", err)
+	}
+
+	return
+}
+
+func TestHandshake(t *testing.T) {
+	host, guest := start(t)
+	defer host.Close()
+	defer guest.Close()
+
+	if host.Opponent != "Guest" || guest.Opponent != "Host" {
+		t.Errorf("wrong opponents: host sees %q, guest sees %q", host.Opponent, guest.Opponent)
+	}
+
+	if host.Seed != 1234 || guest.Seed != 1234 {
+		t.Errorf("wrong seeds: host has %d, guest has %d", host.Seed, guest.Seed)
+	}
+}
+
+func TestVersionMismatch(t *testing.T) {
+	hosting, err := Host("127.0.0.1:0", Player{Name: "Host", Rules: test_rules}, 1234)
+	if err != nil {
+		t.Fatal("could not host: ", err)
+	}
+
+	// Join always speaks the current version, so say hello by hand
+	conn, err := net.Dial("tcp", hosting.Addr().String())
+	if err != nil {
+		t.Fatal("could not connect: ", err)
+	}
+	defer conn.Close()
+
+	data, _ := json.Marshal(Message{Type: MSG_HELLO, Version: PROTOCOL_VERSION + 1, Name: "Guest", Rules: test_rules})
+	conn.Write(append(data, '\n'))
+
+	if _, err := wait(t, hosting); err == nil || !strings.Contains(err.Error(), "version mismatch") {
+		t.Errorf("host accepted the wrong version, error was %v", err)
+	}
+
+	reply := newSession(conn)
+	conn.SetDeadline(time.Now().Add(test_timeout))
+	if msg, err := reply.conn.Receive(); err != nil || msg.Type != MSG_BYE {
+		t.Errorf("guest wasn't told why, got %+v (error %v)", msg, err)
+	}
+}
+
+func TestRulesMismatch(t *testing.T) {
+	hosting, joining := connect(t, Player{Name: "Host", Rules: test_rules}, Player{Name: "Guest", Rules: "4x10 tgm"})
+
+	if _, err := wait(t, hosting); err == nil || !strings.Contains(err.Error(), "rules don't match") {
+		t.Errorf("host accepted the wrong rules, error was %v", err)
+	}
+
+	if _, err := wait(t, joining); err == nil || !strings.Contains(err.Error(), "rules don't match") {
+		t.Errorf("guest accepted the wrong rules, error was %v", err)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	host, guest := start(t)
+	defer host.Close()
+	defer guest.Close()
+
+	sent := []Message{
+		{Type: MSG_ATTACK, Lines: 4},
+		{Type: MSG_BOARD, Board: []string{"..........", "IIII.OO.TT", "GGGGG.GGGG"}},
+		{Type: MSG_GAME_OVER},
+	}
+
+	for _, msg := range sent {
+		if err := host.Send(msg); err != nil {
+			t.Fatal("could not send: ", err)
+		}
+	}
+
+	for _, want := range sent {
+		got, ok := receive(t, guest)
+		if !ok {
+			t.Fatal("connection ended early: ", guest.Err())
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestBye(t *testing.T) {
+	host, guest := start(t)
+	defer guest.Close()
+
+	host.Close()
+
+	if msg, ok := receive(t, guest); ok {
+		t.Fatalf("got %+v after the host left", msg)
+	}
+
+	if err := guest.Err(); err == nil || !strings.Contains(err.Error(), "opponent left") {
+		t.Errorf("wrong reason for the session ending: %v", err)
+	}
+}
+
+// play steps a game with the actions, and passes any attacks it makes on to the opponent.
+func play(t *testing.T, game *engine.Game, s *Session, actions ...engine.Action) {
+	t.Helper()
+
+	for _, e := range game.Step(actions) {
+		if e.Type == engine.EV_ATTACK {
+			if err := s.Send(Message{Type: MSG_ATTACK, Lines: e.Attack}); err != nil {
+				t.Fatal("could not send attack: ", err)
+			}
+		}
+	}
+}
+
+func TestGarbageExchange(t *testing.T) {
+	host, guest := start(t)
+	defer host.Close()
+	defer guest.Close()
+
+	// the host has a quad lined up, with the hole on the right. the row on top keeps it from being a perfect clear
+	config := engine.DefaultConfig()
+	config.Seed = host.Seed
+	config.Attack = engine.GuidelineAttack
+	host_config := config
+	host_config.Setup = &engine.Setup{
+		Board: []string{"GG........", "GGGGGGGGG.", "GGGGGGGGG.", "GGGGGGGGG.", "GGGGGGGGG."},
+		Queue: []engine.PieceType{engine.I, engine.O},
+	}
+
+	var host_game, guest_game engine.Game
+	host_game.Init(host_config)
+	guest_game.Init(config)
+
+	play(t, &host_game, host, engine.ACTION_ROTATE_CW)
+	for range config.WellSize.W {
+		play(t, &host_game, host, engine.ACTION_MOVE_RIGHT)
+		play(t, &host_game, host, engine.ACTION_RELEASE_RIGHT)
+	}
+	play(t, &host_game, host, engine.ACTION_HARD_DROP)
+
+	if lines := host_game.Info().LinesDestroyed; lines != 4 {
+		t.Fatalf("host cleared %d lines, want 4", lines)
+	}
+
+	msg, ok := receive(t, guest)
+	if !ok {
+		t.Fatal("connection ended early: ", guest.Err())
+	}
+	if msg.Type != MSG_ATTACK {
+		t.Fatalf("got %+v, want an attack", msg)
+	}
+
+	want := engine.GuidelineAttack.Lines[4]
+	if msg.Lines != want {
+		t.Errorf("attack was %d lines, want %d", msg.Lines, want)
+	}
+
+	// the garbage waits until the guest locks a piece without clearing anything
+	var garbage []engine.Action
+	for range msg.Lines {
+		garbage = append(garbage, engine.ACTION_GARBAGE)
+	}
+	play(t, &guest_game, guest, garbage...)
+
+	if incoming := guest_game.IncomingGarbage(); incoming != msg.Lines {
+		t.Errorf("guest has %d lines incoming, want %d", incoming, msg.Lines)
+	}
+
+	play(t, &guest_game, guest, engine.ACTION_HARD_DROP)
+
+	if received := guest_game.Info().GarbageReceived; received != msg.Lines {
+		t.Errorf("guest received %d lines of garbage, want %d", received, msg.Lines)
+	}
+
+	if lines := guest_game.Board().GarbageLines(); lines != msg.Lines {
+		t.Errorf("guest's board has %d lines of garbage, want %d", lines, msg.Lines)
+	}
+}
+
+func TestBoardsMerge(t *testing.T) {
+	host, guest := start(t)
+	defer host.Close()
+	defer guest.Close()
+
+	// boards sent faster than they can go out replace each other, but the latest always arrives
+	const boards = 200
+	for i := range boards {
+		host.Send(Message{Type: MSG_BOARD, Board: []string{strconv.Itoa(i)}})
+	}
+	host.Send(Message{Type: MSG_GAME_OVER})
+
+	var received []Message
+	for {
+		msg, ok := receive(t, guest)
+		if !ok {
+			t.Fatal("connection ended early: ", guest.Err())
+		}
+		if msg.Type == MSG_GAME_OVER {
+			break
+		}
+		received = append(received, msg)
+	}
+
+	if len(received) == 0 || len(received) > boards {
+		t.Fatalf("got %d boards", len(received))
+	}
+
+	if last := received[len(received)-1].Board[0]; last != strconv.Itoa(boards-1) {
+		t.Errorf("last board was %s, want %d", last, boards-1)
+	}
+}